@@ -38,20 +38,14 @@ func main() {
 			fmt.Printf("Found marker at Line %d, Column %d (Offset: %d)\n",
 				markerPos.Line(), markerPos.Column(), markerPos.Offset())
 
-			_, err := reader.Seek(-contextBytes, io.SeekCurrent)
+			snippet, err := reader.Context(contextBytes, contextBytes)
 			if err != nil {
-				// If the marker is too close to the start, seek might fail.
-				// In that case, we just go to the very beginning of the
-				// stream.
-				_, _ = reader.Seek(0, io.SeekStart)
+				log.Fatalf("Error getting context: %v", err)
 			}
 
-			contextBuffer := make([]byte, contextBytes*2)
-			n, _ := reader.Read(contextBuffer)
-
 			fmt.Printf(
-				"Context around marker: %q\n",
-				contextBuffer[:n],
+				"Context around marker: %q (starting at Line %d, Column %d)\n",
+				snippet.Text, snippet.Start.Line(), snippet.Start.Column(),
 			)
 
 			return
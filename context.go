@@ -0,0 +1,197 @@
+package textreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/xiam/textreader/position"
+)
+
+// Snippet is a piece of text around the read position, as returned by
+// Context.
+type Snippet struct {
+	// Text is the text around the read position. It never starts or ends in
+	// the middle of a UTF-8 sequence.
+	Text string
+
+	// Start is the position of the first byte of Text.
+	Start *position.Position
+
+	// Cursor is the index in Text of the byte at the read position.
+	Cursor int
+}
+
+// Context returns up to before bytes of already read text and up to after
+// bytes of upcoming text around the current read position, reading ahead if
+// needed. Only buffered data is used, so the snippet may be shorter than
+// requested. Both ends are adjusted to rune boundaries. The reader's position
+// is not changed.
+func (t *TextReader) Context(before, after int) (Snippet, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if before < 0 || after < 0 {
+		return Snippet{}, fmt.Errorf("invalid context size: before=%d, after=%d", before, after)
+	}
+
+	if after > t.w-t.r {
+		// Make sure we don't throw away the text before the read position while
+		// filling the buffer, nor cut the rune it starts in.
+		from := max(t.r-before, 0)
+		for from > 0 && from < t.w && !utf8.RuneStart(t.buf[from]) {
+			from--
+		}
+		id := t.pin(t.pos.Offset() - t.r + from)
+		_, err := t.fillAtLeast(min(after, t.maxCapacity))
+		t.unpin(id)
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Snippet{}, err
+		}
+	}
+
+	start := t.r - before
+	if start < 0 {
+		start = 0
+	}
+
+	// The buffer may start in the middle of a rune, start at the first full
+	// rune we still have.
+	for start < t.r && !utf8.RuneStart(t.buf[start]) {
+		start++
+	}
+
+	// Walk backwards rune by rune so we never split a sequence.
+	i := t.r
+	for i > start {
+		_, size := utf8.DecodeLastRune(t.buf[:i])
+		if i-size < start {
+			break
+		}
+		i -= size
+	}
+	start = i
+
+	end := t.r + after
+	if end > t.w {
+		end = t.w
+	}
+
+	i = t.r
+	for i < end {
+		_, size := utf8.DecodeRune(t.buf[i:t.w])
+		if i+size > end {
+			break
+		}
+		i += size
+	}
+	end = i
+
+	pos := t.pos.Copy()
+	if err := pos.Rewind(t.r-start, utf8.RuneCount(t.buf[start:t.r])); err != nil {
+		return Snippet{}, fmt.Errorf("pos.Rewind: %w", err)
+	}
+
+	return Snippet{
+		Text:   string(t.buf[start:end]),
+		Start:  pos,
+		Cursor: t.r - start,
+	}, nil
+}
+
+// CurrentLine returns the text of the line that contains the read position,
+// without its line break, and the column of the read position within that
+// text, counted in runes. Line breaks are recognized according to the line
+// endings of the reader's position, see position.WithLineEndings. It reads
+// ahead as needed to find the end of the line. If the line doesn't fit in the
+// buffer, the text that could be kept is returned along with ErrLineTruncated.
+// The reader's position is not changed.
+func (t *TextReader) CurrentLine() (text string, column int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lineStart := t.pos.LineStart()
+	endings := t.pos.LineEndings()
+
+	// Keep the beginning of the line in the buffer while we look for its end.
+	id := t.pin(lineStart)
+	defer t.unpin(id)
+
+	truncated, eof := false, false
+	scanned := 0
+
+	end := -1
+	for {
+		// Look again at the tail of what we scanned before, it could hold the
+		// first half of a line break.
+		from := t.r + max(scanned-utf8.UTFMax, 0)
+		if i := endings.Index(t.buf[from:t.w]); i >= 0 {
+			end = from + i
+		}
+		// The line also ends where its source does.
+		if i := t.nextBoundary(); i >= 0 && (end < 0 || i < end) {
+			end = i
+		}
+		if end >= 0 {
+			break
+		}
+
+		scanned = t.w - t.r
+		if eof {
+			end = t.w
+			break
+		}
+
+		if scanned >= t.maxCapacity {
+			truncated = true
+			end = t.w
+
+			// Don't cut the last rune in half.
+			if i := lastRuneStart(t.buf[t.r:end]); i >= 0 && !utf8.FullRune(t.buf[t.r+i:end]) {
+				end = t.r + i
+			}
+			break
+		}
+
+		if _, err := t.fillAtLeast(scanned + 1); err != nil {
+			if !errors.Is(err, io.EOF) {
+				return "", 0, err
+			}
+			eof = true
+		}
+	}
+
+	start := lineStart - (t.pos.Offset() - t.r)
+	if start < 0 {
+		// The beginning of the line didn't fit, start at the first full rune we
+		// still have.
+		truncated = true
+		start = 0
+		for start < t.r && !utf8.RuneStart(t.buf[start]) {
+			start++
+		}
+	}
+
+	text = string(t.buf[start:end])
+	column = utf8.RuneCount(t.buf[start:t.r])
+
+	if truncated {
+		return text, column, ErrLineTruncated
+	}
+
+	return text, column, nil
+}
+
+// lastRuneStart returns the index of the byte where the last rune in b starts,
+// or -1 if there is none.
+func lastRuneStart(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,293 @@
+package textreader
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+func TestContext(t *testing.T) {
+	data := "first line\nsecond line\nthird line\nfourth line\nfifth line 🦄\n"
+
+	t.Run("around the read position", func(t *testing.T) {
+		tr := New(strings.NewReader(data))
+
+		_, err := io.ReadFull(tr, make([]byte, 17))
+		require.NoError(t, err)
+
+		s, err := tr.Context(8, 10)
+		require.NoError(t, err)
+		assert.Equal(t, "e\nsecond line\nthir", s.Text)
+		assert.Equal(t, 8, s.Cursor)
+		assert.Equal(t, 1, s.Start.Line())
+		assert.Equal(t, 9, s.Start.Column())
+		assert.Equal(t, 9, s.Start.Offset())
+
+		// The reader is not disturbed.
+		pos := tr.Pos()
+		assert.Equal(t, 2, pos.Line())
+		assert.Equal(t, 6, pos.Column())
+		assert.Equal(t, 17, pos.Offset())
+
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, ' ', r)
+	})
+
+	t.Run("clipped at the edges", func(t *testing.T) {
+		tr := New(strings.NewReader(data))
+
+		_, _, err := tr.ReadRune()
+		require.NoError(t, err)
+
+		s, err := tr.Context(100, 1000)
+		require.NoError(t, err)
+		assert.Equal(t, data, s.Text)
+		assert.Equal(t, 1, s.Cursor)
+		assert.Equal(t, 0, s.Start.Offset())
+
+		s, err = tr.Context(0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "", s.Text)
+		assert.Equal(t, 0, s.Cursor)
+		assert.Equal(t, 1, s.Start.Offset())
+
+		_, err = tr.Context(-1, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("rune boundaries", func(t *testing.T) {
+		tr := New(strings.NewReader("ab界cd🦄ef"))
+
+		_, err := io.ReadFull(tr, make([]byte, 7))
+		require.NoError(t, err)
+		assert.Equal(t, 5, tr.Pos().Column())
+
+		// Four bytes back would land inside 界, and three bytes ahead inside 🦄.
+		s, err := tr.Context(4, 3)
+		require.NoError(t, err)
+		assert.Equal(t, "cd", s.Text)
+		assert.Equal(t, 3, s.Start.Column())
+		assert.Equal(t, 5, s.Start.Offset())
+		assert.Equal(t, 2, s.Cursor)
+
+		s, err = tr.Context(5, 5)
+		require.NoError(t, err)
+		assert.Equal(t, "界cd🦄e", s.Text)
+		assert.Equal(t, 2, s.Start.Column())
+		assert.Equal(t, 5, s.Cursor)
+
+		s, err = tr.Context(0, 4)
+		require.NoError(t, err)
+		assert.Equal(t, "🦄", s.Text)
+		assert.Equal(t, 0, s.Cursor)
+	})
+
+	t.Run("keeps whole runes behind while filling", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader("é界a"), 4)
+
+		_, _, err := tr.ReadRune()
+		require.NoError(t, err)
+
+		// One byte back lands inside é, which is kept whole or not at all.
+		_, err = tr.Context(1, 3)
+		require.NoError(t, err)
+
+		s, err := tr.Context(9, 0)
+		require.NoError(t, err)
+		assert.True(t, utf8.ValidString(s.Text))
+		assert.Equal(t, 2, s.Start.Offset()+s.Cursor)
+		assert.Equal(t, utf8.RuneCountInString(s.Text), 1-s.Start.Column())
+
+		tr = NewWithCapacity(strings.NewReader("é界a"), 4, WithMarkLimit(8))
+		_, _, err = tr.ReadRune()
+		require.NoError(t, err)
+		_, err = tr.Context(1, 3)
+		require.NoError(t, err)
+
+		s, err = tr.Context(9, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "é", s.Text)
+		assert.Equal(t, 0, s.Start.Offset())
+		assert.Equal(t, 2, s.Cursor)
+	})
+
+	t.Run("nothing behind with a full buffer", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader("abcdef"), 4)
+
+		_, err := io.ReadFull(tr, make([]byte, 4))
+		require.NoError(t, err)
+
+		s, err := tr.Context(0, 2)
+		require.NoError(t, err)
+		assert.Equal(t, "ef", s.Text)
+		assert.Equal(t, 0, s.Cursor)
+
+		// At the end of the input.
+		_, err = io.ReadFull(tr, make([]byte, 2))
+		require.NoError(t, err)
+
+		s, err = tr.Context(0, 2)
+		require.NoError(t, err)
+		assert.Equal(t, "", s.Text)
+		assert.Equal(t, 6, s.Start.Offset())
+	})
+
+	t.Run("keeps text behind while filling", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader(data), 16)
+
+		_, err := io.ReadFull(tr, make([]byte, 14))
+		require.NoError(t, err)
+
+		s, err := tr.Context(4, 8)
+		require.NoError(t, err)
+		assert.Equal(t, "\nsecond line", s.Text)
+		assert.Equal(t, 4, s.Cursor)
+		assert.Equal(t, 10, s.Start.Offset())
+
+		assert.Equal(t, 14, tr.Pos().Offset())
+
+		rest, err := readAllRunes(tr)
+		require.NoError(t, err)
+		assert.Equal(t, data[14:], rest)
+	})
+}
+
+func TestCurrentLine(t *testing.T) {
+	data := "first line\nsecond line\nthird line 🦄 and more\n"
+
+	t.Run("line under the read position", func(t *testing.T) {
+		tr := New(strings.NewReader(data))
+
+		text, column, err := tr.CurrentLine()
+		require.NoError(t, err)
+		assert.Equal(t, "first line", text)
+		assert.Equal(t, 0, column)
+
+		_, err = io.ReadFull(tr, make([]byte, 14))
+		require.NoError(t, err)
+
+		text, column, err = tr.CurrentLine()
+		require.NoError(t, err)
+		assert.Equal(t, "second line", text)
+		assert.Equal(t, 3, column)
+
+		// The reader is not disturbed.
+		pos := tr.Pos()
+		assert.Equal(t, 2, pos.Line())
+		assert.Equal(t, 3, pos.Column())
+		assert.Equal(t, 14, pos.Offset())
+
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 'o', r)
+	})
+
+	t.Run("at the end of a line", func(t *testing.T) {
+		tr := New(strings.NewReader(data))
+
+		_, err := io.ReadFull(tr, make([]byte, 10))
+		require.NoError(t, err)
+
+		text, column, err := tr.CurrentLine()
+		require.NoError(t, err)
+		assert.Equal(t, "first line", text)
+		assert.Equal(t, 10, column)
+
+		_, _, err = tr.ReadRune()
+		require.NoError(t, err)
+
+		text, column, err = tr.CurrentLine()
+		require.NoError(t, err)
+		assert.Equal(t, "second line", text)
+		assert.Equal(t, 0, column)
+	})
+
+	t.Run("last line without line break", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader("abc\ndef 🦄 ghi"), 16)
+
+		_, err := io.ReadFull(tr, make([]byte, 12))
+		require.NoError(t, err)
+
+		text, column, err := tr.CurrentLine()
+		require.NoError(t, err)
+		assert.Equal(t, "def 🦄 ghi", text)
+		assert.Equal(t, 5, column)
+	})
+
+	t.Run("line longer than the buffer", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader(strings.Repeat("x", 40)+"\nnext"), 16)
+
+		_, err := io.ReadFull(tr, make([]byte, 30))
+		require.NoError(t, err)
+
+		text, column, err := tr.CurrentLine()
+		assert.ErrorIs(t, err, ErrLineTruncated)
+		assert.NotEmpty(t, text)
+		assert.LessOrEqual(t, len(text), 16)
+		assert.LessOrEqual(t, column, len(text))
+		assert.Equal(t, 30, tr.Pos().Offset())
+
+		rest, err := readAllRunes(tr)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat("x", 10)+"\nnext", rest)
+	})
+
+	t.Run("line fits with a larger mark limit", func(t *testing.T) {
+		line := strings.Repeat("x", 24)
+
+		tr := NewWithCapacity(strings.NewReader(line+"\nnext"), 16)
+		_, err := io.ReadFull(tr, make([]byte, 12))
+		require.NoError(t, err)
+
+		_, _, err = tr.CurrentLine()
+		assert.ErrorIs(t, err, ErrLineTruncated)
+
+		tr = NewWithCapacity(strings.NewReader(line+"\nnext"), 16, WithMarkLimit(64))
+		_, err = io.ReadFull(tr, make([]byte, 12))
+		require.NoError(t, err)
+
+		text, column, err := tr.CurrentLine()
+		require.NoError(t, err)
+		assert.Equal(t, line, text)
+		assert.Equal(t, 12, column)
+	})
+
+	t.Run("line endings", func(t *testing.T) {
+		data := "first\r\nsecond\u2028third"
+
+		tr := New(strings.NewReader(data), WithPositionOptions(position.WithLineEndings(position.CRLF)))
+
+		_, err := io.ReadFull(tr, make([]byte, 9))
+		require.NoError(t, err)
+
+		text, column, err := tr.CurrentLine()
+		require.NoError(t, err)
+		assert.Equal(t, "second\u2028third", text)
+		assert.Equal(t, 2, column)
+
+		tr = New(strings.NewReader(data), WithPositionOptions(position.WithLineEndings(position.Unicode)))
+
+		_, err = io.ReadFull(tr, make([]byte, 9))
+		require.NoError(t, err)
+
+		text, column, err = tr.CurrentLine()
+		require.NoError(t, err)
+		assert.Equal(t, "second", text)
+		assert.Equal(t, 2, column)
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		tr := New(strings.NewReader(""))
+
+		text, column, err := tr.CurrentLine()
+		require.NoError(t, err)
+		assert.Equal(t, "", text)
+		assert.Equal(t, 0, column)
+	})
+}
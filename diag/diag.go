@@ -0,0 +1,307 @@
+// Package diag renders compiler-style diagnostics that point at a location in
+// a text, like:
+//
+//	error: unexpected character
+//	 --> config.txt:3:7
+//	  |
+//	3 | key = @value
+//	  |       ^^^^^^ expected a string
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"github.com/xiam/textreader"
+	"github.com/xiam/textreader/position"
+)
+
+const defaultTabWidth = 4
+
+// Severity is the kind of a diagnostic.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// ANSI escape sequences used by the colored output.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiCyan   = "\x1b[1;36m"
+	ansiBlue   = "\x1b[1;34m"
+)
+
+func (s Severity) color() string {
+	switch s {
+	case Error:
+		return ansiRed
+	case Warning:
+		return ansiYellow
+	}
+
+	return ansiCyan
+}
+
+// Diagnostic describes a problem found in a line of text.
+type Diagnostic struct {
+	Severity Severity
+	Message  string
+
+	// File is the name of the file the line belongs to, if any.
+	File string
+
+	// Line is the line number, starting at 1.
+	Line int
+
+	// Source is the text of the line, without its line break.
+	Source string
+
+	// Start and End delimit the byte range within Source the diagnostic
+	// points at. An empty range is rendered as a single caret.
+	Start int
+	End   int
+
+	// Label is an optional note printed next to the carets.
+	Label string
+
+	// Col is the column Start is at, starting at 1, as counted by the column
+	// mode of the position it came from (see position.WithColumnMode). If
+	// zero, Column counts runes in Source instead.
+	Col int
+}
+
+// FromReader returns a diagnostic for the n bytes that start at the read
+// position of r, using the line that contains it as source. The reader's
+// position is not changed. A line that is too long to fit in the reader's
+// buffer is truncated.
+func FromReader(r *textreader.TextReader, severity Severity, message string, n int) (*Diagnostic, error) {
+	text, column, err := r.CurrentLine()
+	if err != nil && !errors.Is(err, textreader.ErrLineTruncated) {
+		return nil, err
+	}
+
+	start := runeOffset(text, column)
+	pos := r.Pos()
+
+	return &Diagnostic{
+		Severity: severity,
+		Message:  message,
+		File:     pos.Name(),
+		Line:     pos.Line(),
+		Source:   text,
+		Start:    start,
+		End:      start + n,
+		Col:      pos.Column() + 1,
+	}, nil
+}
+
+// FromSnippet returns a diagnostic for the n bytes that start at the cursor of
+// the given snippet, using the line of the snippet that contains the cursor as
+// source. Lines are broken according to the line endings of the snippet's
+// position, see position.WithLineEndings.
+func FromSnippet(s textreader.Snippet, severity Severity, message string, n int) *Diagnostic {
+	// Scan up to the cursor to find the line it's on.
+	pos := s.Start.Copy()
+	pos.Scan([]byte(s.Text[:s.Cursor]))
+
+	lineStart := max(pos.LineStart()-s.Start.Offset(), 0)
+
+	lineEnd := pos.LineEndings().Index([]byte(s.Text[s.Cursor:]))
+	if lineEnd < 0 {
+		lineEnd = len(s.Text)
+	} else {
+		lineEnd += s.Cursor
+	}
+
+	return &Diagnostic{
+		Severity: severity,
+		Message:  message,
+		File:     pos.Name(),
+		Line:     pos.Line(),
+		Source:   s.Text[lineStart:lineEnd],
+		Start:    s.Cursor - lineStart,
+		End:      s.Cursor - lineStart + n,
+		Col:      pos.Column() + 1,
+	}
+}
+
+// Column returns the column the diagnostic points at, starting at 1. That is
+// Col if set, or else the number of runes in Source before Start plus one.
+func (d *Diagnostic) Column() int {
+	if d.Col > 0 {
+		return d.Col
+	}
+
+	start, _ := d.span()
+	return utf8.RuneCountInString(d.Source[:start]) + 1
+}
+
+// String returns the diagnostic rendered as plain text.
+func (d *Diagnostic) String() string {
+	var sb strings.Builder
+	_ = Printer{}.Fprint(&sb, d)
+	return sb.String()
+}
+
+// span returns Start and End clamped to the source text and adjusted to rune
+// boundaries.
+func (d *Diagnostic) span() (int, int) {
+	start, end := d.Start, d.End
+
+	if start < 0 {
+		start = 0
+	}
+	if start > len(d.Source) {
+		start = len(d.Source)
+	}
+	for start > 0 && start < len(d.Source) && !utf8.RuneStart(d.Source[start]) {
+		start--
+	}
+
+	if end < start {
+		end = start
+	}
+	if end > len(d.Source) {
+		end = len(d.Source)
+	}
+	for end < len(d.Source) && !utf8.RuneStart(d.Source[end]) {
+		end++
+	}
+
+	return start, end
+}
+
+// Printer renders diagnostics.
+type Printer struct {
+	// TabWidth is the distance between tab stops used to expand tabs in the
+	// source line. Defaults to 4.
+	TabWidth int
+
+	// Color enables ANSI escape sequences in the output.
+	Color bool
+
+	// Style numbers the line and column of the location. Defaults to
+	// position.EditorStyle, both start at 1.
+	Style *position.Style
+}
+
+// Fprint writes the rendered diagnostic to w.
+func (p Printer) Fprint(w io.Writer, d *Diagnostic) error {
+	tabWidth := p.TabWidth
+	if tabWidth < 1 {
+		tabWidth = defaultTabWidth
+	}
+
+	paint := func(color, s string) string {
+		if !p.Color || s == "" {
+			return s
+		}
+		return color + s + ansiReset
+	}
+
+	start, end := d.span()
+
+	lineNum := strconv.Itoa(d.Line)
+	gutter := strings.Repeat(" ", len(lineNum))
+
+	style := position.EditorStyle
+	if p.Style != nil {
+		style = *p.Style
+	}
+
+	location := fmt.Sprintf("%d:%d", d.Line-1+style.LineBase, d.Column()-1+style.ColBase)
+	if d.File != "" {
+		location = d.File + ":" + location
+	}
+
+	// Expand the source line and measure where the carets go.
+	var src strings.Builder
+	col, from, to := 0, 0, 0
+	gr := uniseg.NewGraphemes(d.Source)
+	for gr.Next() {
+		i, next := gr.Positions()
+		if start >= i && start < next {
+			from = col
+		}
+		if end == i {
+			to = col
+		}
+
+		if cluster := gr.Str(); cluster == "\t" {
+			n := tabWidth - col%tabWidth
+			src.WriteString(strings.Repeat(" ", n))
+			col += n
+		} else {
+			// Wide characters like CJK and emoji take two cells, see
+			// position.Cells.
+			src.WriteString(cluster)
+			col += gr.Width()
+		}
+
+		if end > i && end < next {
+			to = col
+		}
+	}
+	if start == len(d.Source) {
+		from = col
+	}
+	if end == len(d.Source) {
+		to = col
+	}
+
+	carets := to - from
+	if carets < 1 {
+		carets = 1
+	}
+
+	underline := strings.Repeat(" ", from) + paint(d.Severity.color(), strings.Repeat("^", carets))
+	if d.Label != "" {
+		underline += " " + paint(d.Severity.color(), d.Label)
+	}
+
+	_, err := fmt.Fprintf(w, "%s%s\n%s%s %s\n%s %s\n%s %s %s\n%s %s %s\n",
+		paint(d.Severity.color(), d.Severity.String()+":"),
+		paint(ansiBold, " "+d.Message),
+		gutter, paint(ansiBlue, "-->"), location,
+		gutter, paint(ansiBlue, "|"),
+		paint(ansiBlue, lineNum), paint(ansiBlue, "|"), src.String(),
+		gutter, paint(ansiBlue, "|"), underline,
+	)
+
+	return err
+}
+
+// runeOffset returns the byte offset of the n-th rune in s.
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
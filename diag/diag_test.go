@@ -0,0 +1,244 @@
+package diag_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader"
+	"github.com/xiam/textreader/diag"
+	"github.com/xiam/textreader/position"
+)
+
+func TestDiagnostic(t *testing.T) {
+	t.Run("plain", func(t *testing.T) {
+		d := &diag.Diagnostic{
+			Severity: diag.Error,
+			Message:  "unexpected character",
+			File:     "config.txt",
+			Line:     3,
+			Source:   "key = @value",
+			Start:    6,
+			End:      12,
+			Label:    "expected a string",
+		}
+
+		expected := "" +
+			"error: unexpected character\n" +
+			" --> config.txt:3:7\n" +
+			"  |\n" +
+			"3 | key = @value\n" +
+			"  |       ^^^^^^ expected a string\n"
+
+		assert.Equal(t, expected, d.String())
+		assert.Equal(t, 7, d.Column())
+	})
+
+	t.Run("tabs and multi-byte runes", func(t *testing.T) {
+		d := &diag.Diagnostic{
+			Severity: diag.Warning,
+			Message:  "suspicious value",
+			Line:     12,
+			Source:   "\tname:\t\"日本🦄\"",
+			Start:    len("\tname:\t\""),
+			End:      len("\tname:\t\"日本"),
+		}
+
+		expected := "" +
+			"warning: suspicious value\n" +
+			"  --> 12:9\n" +
+			"   |\n" +
+			"12 |     name:   \"日本🦄\"\n" +
+			"   |              ^^^^\n"
+
+		var sb strings.Builder
+		err := diag.Printer{TabWidth: 4}.Fprint(&sb, d)
+		require.NoError(t, err)
+		assert.Equal(t, expected, sb.String())
+
+		expected = "" +
+			"warning: suspicious value\n" +
+			"  --> 12:9\n" +
+			"   |\n" +
+			"12 |   name: \"日本🦄\"\n" +
+			"   |          ^^^^\n"
+
+		sb.Reset()
+		err = diag.Printer{TabWidth: 2}.Fprint(&sb, d)
+		require.NoError(t, err)
+		assert.Equal(t, expected, sb.String())
+
+		sb.Reset()
+		err = diag.Printer{TabWidth: 2, Style: &position.LSPStyle}.Fprint(&sb, d)
+		require.NoError(t, err)
+		assert.Contains(t, sb.String(), "  --> 11:8\n")
+	})
+
+	t.Run("wide characters", func(t *testing.T) {
+		d := &diag.Diagnostic{
+			Message: "unexpected character",
+			Line:    1,
+			Source:  "名前 = @x",
+			Start:   len("名前 = "),
+			End:     len("名前 = @x"),
+		}
+
+		expected := "" +
+			"error: unexpected character\n" +
+			" --> 1:6\n" +
+			"  |\n" +
+			"1 | 名前 = @x\n" +
+			"  |        ^^\n"
+
+		var sb strings.Builder
+		err := diag.Printer{}.Fprint(&sb, d)
+		require.NoError(t, err)
+		assert.Equal(t, expected, sb.String())
+
+		// Combining marks take no room.
+		d = &diag.Diagnostic{
+			Line:   1,
+			Source: "cafe\u0301 = 1",
+			Start:  len("cafe\u0301 = "),
+			End:    len("cafe\u0301 = 1"),
+		}
+
+		sb.Reset()
+		err = diag.Printer{}.Fprint(&sb, d)
+		require.NoError(t, err)
+		assert.Contains(t, sb.String(), "\n  |        ^\n")
+	})
+
+	t.Run("empty span and out of range", func(t *testing.T) {
+		d := &diag.Diagnostic{
+			Severity: diag.Note,
+			Message:  "missing semicolon",
+			Line:     1,
+			Source:   "let x = 1",
+			Start:    9,
+			End:      9,
+		}
+
+		assert.Equal(t, ""+
+			"note: missing semicolon\n"+
+			" --> 1:10\n"+
+			"  |\n"+
+			"1 | let x = 1\n"+
+			"  |          ^\n", d.String())
+
+		d.Start, d.End = 4, 100
+		assert.Equal(t, ""+
+			"note: missing semicolon\n"+
+			" --> 1:5\n"+
+			"  |\n"+
+			"1 | let x = 1\n"+
+			"  |     ^^^^^\n", d.String())
+	})
+
+	t.Run("color", func(t *testing.T) {
+		d := &diag.Diagnostic{
+			Severity: diag.Error,
+			Message:  "oops",
+			Line:     1,
+			Source:   "abc",
+			Start:    1,
+			End:      2,
+		}
+
+		var sb strings.Builder
+		err := diag.Printer{Color: true}.Fprint(&sb, d)
+		require.NoError(t, err)
+
+		expected := "" +
+			"\x1b[1;31merror:\x1b[0m\x1b[1m oops\x1b[0m\n" +
+			" \x1b[1;34m-->\x1b[0m 1:2\n" +
+			"  \x1b[1;34m|\x1b[0m\n" +
+			"\x1b[1;34m1\x1b[0m \x1b[1;34m|\x1b[0m abc\n" +
+			"  \x1b[1;34m|\x1b[0m  \x1b[1;31m^\x1b[0m\n"
+
+		assert.Equal(t, expected, sb.String())
+	})
+}
+
+func TestFromReader(t *testing.T) {
+	data := "first line\nsecond = 'oops'\nthird line\n"
+
+	tr := textreader.NewNamed("input.txt", strings.NewReader(data))
+	_, err := io.ReadFull(tr, make([]byte, 20))
+	require.NoError(t, err)
+
+	d, err := diag.FromReader(tr, diag.Error, "unterminated string", 6)
+	require.NoError(t, err)
+
+	assert.Equal(t, ""+
+		"error: unterminated string\n"+
+		" --> input.txt:2:10\n"+
+		"  |\n"+
+		"2 | second = 'oops'\n"+
+		"  |          ^^^^^^\n", d.String())
+
+	assert.Equal(t, 20, tr.Pos().Offset())
+}
+
+func TestFromSnippet(t *testing.T) {
+	data := "first line\nsecond = 'oops'\nthird line\n"
+
+	tr := textreader.New(strings.NewReader(data))
+	_, err := io.ReadFull(tr, make([]byte, 20))
+	require.NoError(t, err)
+
+	s, err := tr.Context(15, 15)
+	require.NoError(t, err)
+
+	d := diag.FromSnippet(s, diag.Warning, "single quotes", 1)
+
+	assert.Equal(t, 2, d.Line)
+	assert.Equal(t, "second = 'oops'", d.Source)
+	assert.Equal(t, 10, d.Column())
+
+	assert.Equal(t, ""+
+		"warning: single quotes\n"+
+		" --> 2:10\n"+
+		"  |\n"+
+		"2 | second = 'oops'\n"+
+		"  |          ^\n", d.String())
+}
+
+func TestColumnMode(t *testing.T) {
+	opts := textreader.WithPositionOptions(position.WithColumnMode(position.Graphemes))
+
+	tr := textreader.New(strings.NewReader("éx"), opts)
+	_, err := io.ReadFull(tr, make([]byte, len("é")))
+	require.NoError(t, err)
+
+	// The location agrees with the reader on what a column is.
+	d, err := diag.FromReader(tr, diag.Error, "unexpected x", 1)
+	require.NoError(t, err)
+	assert.Equal(t, tr.Pos().Column()+1, d.Column())
+	assert.Equal(t, 2, d.Column())
+	assert.Contains(t, d.String(), " --> 1:2\n")
+
+	s, err := tr.Context(8, 8)
+	require.NoError(t, err)
+	d = diag.FromSnippet(s, diag.Error, "unexpected x", 1)
+	assert.Equal(t, 2, d.Column())
+}
+
+func TestFromSnippet_LineEndings(t *testing.T) {
+	data := "first line\rsecond = 'oops'\rthird line\r"
+	opts := textreader.WithPositionOptions(position.WithLineEndings(position.CR))
+
+	tr := textreader.New(strings.NewReader(data), opts)
+	_, err := io.ReadFull(tr, make([]byte, 20))
+	require.NoError(t, err)
+
+	s, err := tr.Context(15, 15)
+	require.NoError(t, err)
+
+	d := diag.FromSnippet(s, diag.Warning, "single quotes", 1)
+	assert.Equal(t, 2, d.Line)
+	assert.Equal(t, "second = 'oops'", d.Source)
+	assert.Equal(t, 10, d.Column())
+}
@@ -0,0 +1,214 @@
+package textreader
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/xiam/textreader/position"
+)
+
+// Encoding is the character encoding of the input of a TextReader. Input that
+// is not UTF-8 is decoded into UTF-8 before it's buffered, so everything the
+// reader returns is UTF-8.
+type Encoding int
+
+const (
+	// UTF8 reads the input as is. This is the default.
+	UTF8 Encoding = iota
+
+	// UTF16LE decodes little-endian UTF-16. A leading byte order mark is
+	// skipped.
+	UTF16LE
+
+	// UTF16BE decodes big-endian UTF-16. A leading byte order mark is skipped.
+	UTF16BE
+
+	// Latin1 decodes ISO-8859-1.
+	Latin1
+
+	// DetectBOM picks UTF-8, UTF-16LE or UTF-16BE from the byte order mark at
+	// the start of the input, and skips it. Input without one is read as
+	// UTF-8.
+	DetectBOM
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+const decoderBufferSize = 4096
+
+// WithEncoding sets the encoding of the input. The position of the reader
+// keeps counting offsets in the decoded UTF-8 text, while
+// position.Position.SourceOffset points into the original input.
+func WithEncoding(enc Encoding) Option {
+	return func(t *TextReader) {
+		t.encoding = enc
+	}
+}
+
+// decoder turns the input into UTF-8.
+type decoder struct {
+	r   io.Reader
+	enc Encoding
+
+	bom     int  // size of the byte order mark that was skipped
+	sniffed bool // whether we looked for a byte order mark already
+
+	raw    []byte // input that was not decoded yet
+	rawBuf [decoderBufferSize]byte
+
+	out    []byte // decoded text that was not returned yet
+	outBuf []byte
+
+	err  error // error from r, returned once everything else is
+	size int   // number of bytes read from r
+}
+
+func newDecoder(r io.Reader, enc Encoding) *decoder {
+	return &decoder{
+		r:      r,
+		enc:    enc,
+		outBuf: make([]byte, 0, decoderBufferSize*2),
+	}
+}
+
+// sniff looks for a byte order mark at the start of the input and settles the
+// encoding.
+func (d *decoder) sniff() {
+	if d.sniffed {
+		return
+	}
+
+	for len(d.raw) < len(bomUTF8) && d.err == nil {
+		if d.read() == 0 && d.err == nil {
+			break
+		}
+	}
+
+	d.sniffed = true
+
+	switch {
+	case d.enc == DetectBOM && bytes.HasPrefix(d.raw, bomUTF8):
+		d.enc, d.bom = UTF8, len(bomUTF8)
+	case (d.enc == DetectBOM || d.enc == UTF16LE) && bytes.HasPrefix(d.raw, bomUTF16LE):
+		d.enc, d.bom = UTF16LE, len(bomUTF16LE)
+	case (d.enc == DetectBOM || d.enc == UTF16BE) && bytes.HasPrefix(d.raw, bomUTF16BE):
+		d.enc, d.bom = UTF16BE, len(bomUTF16BE)
+	case d.enc == DetectBOM:
+		d.enc = UTF8
+	}
+
+	d.raw = d.raw[d.bom:]
+}
+
+// source returns the position option that maps offsets in the decoded text
+// back to the input.
+func (d *decoder) source() position.Option {
+	switch d.enc {
+	case UTF16LE, UTF16BE:
+		return position.WithSource(position.SourceUTF16, d.bom)
+	case Latin1:
+		return position.WithSource(position.SourceLatin1, d.bom)
+	}
+
+	return position.WithSource(position.SourceUTF8, d.bom)
+}
+
+// read appends more input to raw, returning the number of bytes read.
+func (d *decoder) read() int {
+	n := copy(d.rawBuf[:], d.raw)
+
+	var m int
+	m, d.err = d.r.Read(d.rawBuf[n:])
+	d.raw = d.rawBuf[:n+m]
+	d.size += m
+
+	return m
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	d.sniff()
+
+	for len(d.out) == 0 {
+		if len(d.raw) == 0 && d.err != nil {
+			return 0, d.err
+		}
+
+		if len(d.raw) == 0 || !d.decode() {
+			if d.err != nil {
+				continue
+			}
+			if d.read() == 0 && d.err == nil {
+				return 0, nil
+			}
+		}
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+
+	return n, nil
+}
+
+// decode decodes as much of raw as it can into out, and reports whether it
+// decoded anything. Incomplete sequences at the end of raw are left for the
+// next call, unless there's no more input.
+func (d *decoder) decode() bool {
+	eof := d.err != nil
+	out := d.outBuf[:0]
+
+	i := 0
+	switch d.enc {
+	case UTF8:
+		out = append(out, d.raw...)
+		i = len(d.raw)
+	case Latin1:
+		for ; i < len(d.raw); i++ {
+			out = utf8.AppendRune(out, rune(d.raw[i]))
+		}
+	case UTF16LE, UTF16BE:
+		unit := func(j int) rune {
+			if d.enc == UTF16LE {
+				return rune(d.raw[j]) | rune(d.raw[j+1])<<8
+			}
+			return rune(d.raw[j])<<8 | rune(d.raw[j+1])
+		}
+
+		for i+1 < len(d.raw) {
+			r := unit(i)
+			if utf16.IsSurrogate(r) && r < 0xdc00 {
+				if i+3 >= len(d.raw) && !eof {
+					break // wait for the rest of the pair
+				}
+				if i+3 < len(d.raw) {
+					if dec := utf16.DecodeRune(r, unit(i+2)); dec != utf8.RuneError {
+						out = utf8.AppendRune(out, dec)
+						i += 4
+						continue
+					}
+				}
+			}
+			if utf16.IsSurrogate(r) {
+				r = utf8.RuneError
+			}
+			out = utf8.AppendRune(out, r)
+			i += 2
+		}
+
+		if eof && i < len(d.raw) {
+			// A lone byte at the end of the input.
+			out = utf8.AppendRune(out, utf8.RuneError)
+			i = len(d.raw)
+		}
+	}
+
+	d.raw = d.raw[i:]
+	d.out = out
+
+	return len(out) > 0
+}
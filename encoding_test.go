@@ -0,0 +1,168 @@
+package textreader
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeUTF16(s string, bigEndian bool) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	return b
+}
+
+func TestEncoding(t *testing.T) {
+	text := "héllo\nwörld 🦄!\n"
+
+	testCases := []struct {
+		name     string
+		encoding Encoding
+		input    []byte
+		source   int // source offset right before the unicorn
+	}{
+		{"UTF-16LE", UTF16LE, encodeUTF16(text, false), 24},
+		{"UTF-16LE with BOM", UTF16LE, append([]byte{0xff, 0xfe}, encodeUTF16(text, false)...), 26},
+		{"UTF-16BE", UTF16BE, encodeUTF16(text, true), 24},
+		{"detect UTF-16BE", DetectBOM, append([]byte{0xfe, 0xff}, encodeUTF16(text, true)...), 26},
+		{"detect UTF-16LE", DetectBOM, append([]byte{0xff, 0xfe}, encodeUTF16(text, false)...), 26},
+		{"detect UTF-8", DetectBOM, append([]byte{0xef, 0xbb, 0xbf}, text...), 17},
+		{"detect nothing", DetectBOM, []byte(text), 14},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, r := range []io.Reader{bytes.NewReader(tc.input), iotest.OneByteReader(bytes.NewReader(tc.input))} {
+				tr := NewWithCapacity(r, 8, WithEncoding(tc.encoding))
+
+				// Stop right before the unicorn.
+				for i := 0; i < len("héllo\nwörld "); {
+					_, size, err := tr.ReadRune()
+					require.NoError(t, err)
+					i += size
+				}
+
+				pos := tr.Pos()
+				assert.Equal(t, 2, pos.Line())
+				assert.Equal(t, 6, pos.Column())
+				assert.Equal(t, len("héllo\nwörld "), pos.Offset())
+				assert.Equal(t, tc.source, pos.SourceOffset())
+
+				rest, err := readAllRunes(tr)
+				require.NoError(t, err)
+				assert.Equal(t, "🦄!\n", rest)
+				assert.Equal(t, len(tc.input), tr.Pos().SourceOffset())
+			}
+		})
+	}
+}
+
+func TestEncoding_Latin1(t *testing.T) {
+	input := []byte("caf\xe9\n\xbfqu\xe9?")
+
+	tr := New(bytes.NewReader(input), WithEncoding(Latin1))
+
+	out, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "café\n¿qué?", string(out))
+
+	pos := tr.Pos()
+	assert.Equal(t, 2, pos.Line())
+	assert.Equal(t, 5, pos.Column())
+	assert.Equal(t, len("café\n¿qué?"), pos.Offset())
+	assert.Equal(t, len(input), pos.SourceOffset())
+
+	_, err = tr.Seek(-3, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, 3, tr.Pos().Column())
+	assert.Equal(t, len(input)-2, tr.Pos().SourceOffset())
+}
+
+func TestEncoding_InvalidUTF16(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{"lone high surrogate", []byte{0x3d, 0xd8, 'a', 0}, "�a"},
+		{"lone low surrogate", []byte{'a', 0, 0x84, 0xdd}, "a�"},
+		{"high surrogate at the end", []byte{'a', 0, 0x3d, 0xd8}, "a�"},
+		{"odd length", []byte{'a', 0, 'b'}, "a�"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := New(iotest.OneByteReader(bytes.NewReader(tc.input)), WithEncoding(UTF16LE))
+
+			out, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(out))
+			assert.Equal(t, len(tc.input), tr.Pos().SourceOffset())
+		})
+	}
+}
+
+func TestEncoding_OddLength(t *testing.T) {
+	input := []byte{0xff, 0xfe, 'a', 0, '\n', 0, 'b', 0, 'c'}
+
+	tr := New(bytes.NewReader(input), WithEncoding(DetectBOM))
+
+	sources := []int{4, 6, 8, 9}
+	for _, source := range sources {
+		_, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, source, tr.Pos().SourceOffset())
+	}
+
+	_, _, err := tr.ReadRune()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, len(input), tr.Pos().SourceOffset())
+
+	// Going back over the last rune and reading it again.
+	require.NoError(t, tr.UnreadRune())
+	assert.Equal(t, 8, tr.Pos().SourceOffset())
+	_, _, err = tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, len(input), tr.Pos().SourceOffset())
+}
+
+func TestEncoding_MarkBeforeRead(t *testing.T) {
+	input := append([]byte{0xff, 0xfe}, encodeUTF16("ab\ncd", false)...)
+
+	tr := New(bytes.NewReader(input), WithEncoding(DetectBOM))
+
+	m := tr.Mark()
+	assert.Equal(t, 2, m.Pos().SourceOffset())
+
+	_, err := readAllRunes(tr)
+	require.NoError(t, err)
+	assert.Equal(t, len(input), tr.Pos().SourceOffset())
+
+	require.NoError(t, tr.Reset(m))
+	assert.Equal(t, 2, tr.Pos().SourceOffset())
+
+	out, err := readAllRunes(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "ab\ncd", out)
+}
+
+func TestEncoding_ReadError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("a\x00"), iotest.ErrReader(io.ErrUnexpectedEOF))
+
+	tr := New(r, WithEncoding(UTF16LE))
+
+	_, err := io.ReadAll(tr)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,38 @@
+package textreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xiam/textreader/position"
+)
+
+// PosError records an error along with the operation and the position of the
+// reader when it happened. Read, ReadRune, Seek and UnreadRune return their
+// errors as a *PosError, except for io.EOF and *InvalidUTF8Error, which
+// already carries a position.
+type PosError struct {
+	Op  string
+	Pos *position.Position
+	Err error
+}
+
+func (e *PosError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Pos, e.Op, e.Err)
+}
+
+func (e *PosError) Unwrap() error {
+	return e.Err
+}
+
+// wrapError returns err as a *PosError for the given operation at the current
+// position, see PosError.
+func (t *TextReader) wrapError(op string, err error) error {
+	var invalid *InvalidUTF8Error
+	if err == nil || err == io.EOF || errors.As(err, &invalid) {
+		return err
+	}
+
+	return &PosError{Op: op, Pos: t.pos.Copy(), Err: err}
+}
@@ -0,0 +1,89 @@
+package textreader
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPosError(t *testing.T) {
+	t.Run("seek", func(t *testing.T) {
+		tr := New(strings.NewReader("ab\ncd"))
+
+		_, err := io.ReadFull(tr, make([]byte, 4))
+		require.NoError(t, err)
+
+		_, err = tr.Seek(-10, io.SeekCurrent)
+		require.Error(t, err)
+
+		var e *PosError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "seek", e.Op)
+		assert.Equal(t, 2, e.Pos.Line())
+		assert.Equal(t, 1, e.Pos.Column())
+		assert.Equal(t, "2:1: seek: textreader: negative position", err.Error())
+
+		_, err = tr.Seek(100, io.SeekStart)
+		assert.ErrorIs(t, err, ErrSeekOutOfBuffer)
+		assert.True(t, errors.As(err, &e))
+	})
+
+	t.Run("unread rune", func(t *testing.T) {
+		tr := New(strings.NewReader("ab"))
+
+		err := tr.UnreadRune()
+
+		var e *PosError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "unread rune", e.Op)
+		assert.ErrorIs(t, err, bufio.ErrInvalidUnreadRune)
+	})
+
+	t.Run("underlying reader", func(t *testing.T) {
+		failure := errors.New("disk on fire")
+		r := io.MultiReader(strings.NewReader("x\nyz"), iotest.ErrReader(failure))
+
+		tr := NewWithCapacity(r, 4)
+
+		_, err := io.ReadFull(tr, make([]byte, 3))
+		require.NoError(t, err)
+
+		_, _, err = tr.ReadRune()
+		assert.ErrorIs(t, err, failure)
+
+		var e *PosError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "read rune", e.Op)
+		assert.Equal(t, "2:1: read rune: disk on fire", err.Error())
+
+		_, err = New(iotest.ErrReader(failure)).Read(make([]byte, 4))
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "read", e.Op)
+	})
+
+	t.Run("EOF is not wrapped", func(t *testing.T) {
+		tr := New(strings.NewReader(""))
+
+		_, _, err := tr.ReadRune()
+		assert.Equal(t, io.EOF, err)
+
+		_, err = tr.Read(make([]byte, 4))
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("invalid UTF-8 is not wrapped", func(t *testing.T) {
+		tr := New(strings.NewReader("\xff"), WithInvalidUTF8(StrictUTF8))
+
+		_, _, err := tr.ReadRune()
+
+		var e *InvalidUTF8Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "1:0: invalid UTF-8 encoding: ff", err.Error())
+	})
+}
@@ -2,7 +2,10 @@ module github.com/xiam/textreader
 
 go 1.21.1
 
-require github.com/stretchr/testify v1.9.0
+require (
+	github.com/rivo/uniseg v0.4.7
+	github.com/stretchr/testify v1.9.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
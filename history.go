@@ -0,0 +1,66 @@
+package textreader
+
+// runeHistory is a ring that remembers the sizes of the most recent runes
+// returned by ReadRune, so they can be unread in reverse order.
+type runeHistory struct {
+	sizes []int
+	head  int // slot for the next entry
+	n     int // number of valid entries
+}
+
+func newRuneHistory(depth int) runeHistory {
+	if depth < 1 {
+		depth = 1
+	}
+
+	return runeHistory{sizes: make([]int, depth)}
+}
+
+// push records the size of a rune that was just read, overwriting the oldest
+// entry if the ring is full.
+func (h *runeHistory) push(size int) {
+	h.sizes[h.head] = size
+	h.head = (h.head + 1) % len(h.sizes)
+
+	if h.n < len(h.sizes) {
+		h.n++
+	}
+}
+
+// last returns the size of the most recently read rune.
+func (h *runeHistory) last() (int, bool) {
+	if h.n == 0 {
+		return 0, false
+	}
+
+	return h.sizes[(h.head-1+len(h.sizes))%len(h.sizes)], true
+}
+
+// pop forgets the most recently read rune.
+func (h *runeHistory) pop() {
+	if h.n == 0 {
+		return
+	}
+
+	h.head = (h.head - 1 + len(h.sizes)) % len(h.sizes)
+	h.n--
+}
+
+// clear forgets all entries.
+func (h *runeHistory) clear() {
+	h.n = 0
+}
+
+// trim keeps only the most recent entries that add up to at most avail bytes,
+// which is the amount of already-read data still held in the buffer.
+func (h *runeHistory) trim(avail int) {
+	total := 0
+
+	for i := 0; i < h.n; i++ {
+		total += h.sizes[(h.head-1-i+2*len(h.sizes))%len(h.sizes)]
+		if total > avail {
+			h.n = i
+			return
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package textreader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/xiam/textreader/position"
+)
+
+// InvalidUTF8Mode selects what the reader does with input that is not valid
+// UTF-8.
+type InvalidUTF8Mode int
+
+const (
+	// ReplaceInvalidUTF8 reads every invalid byte as utf8.RuneError. This is
+	// the default.
+	ReplaceInvalidUTF8 InvalidUTF8Mode = iota
+
+	// StrictUTF8 stops at invalid input: ReadRune and Read return an
+	// *InvalidUTF8Error and don't move past it. Seek past the offending bytes
+	// to carry on.
+	StrictUTF8
+
+	// CollectInvalidUTF8 reads invalid input like ReplaceInvalidUTF8, but
+	// remembers every invalid sequence that is read, see InvalidUTF8.
+	CollectInvalidUTF8
+)
+
+// InvalidUTF8Error reports input that is not valid UTF-8. It matches
+// ErrInvalidUTF8 with errors.Is.
+type InvalidUTF8Error struct {
+	// Bytes is the invalid sequence.
+	Bytes []byte
+
+	// Pos is the position of the first byte of the sequence.
+	Pos *position.Position
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("%s: %v: % x", e.Pos, ErrInvalidUTF8, e.Bytes)
+}
+
+func (e *InvalidUTF8Error) Unwrap() error {
+	return ErrInvalidUTF8
+}
+
+// WithInvalidUTF8 sets what the reader does with input that is not valid
+// UTF-8. In StrictUTF8 and CollectInvalidUTF8 modes Read never returns part of
+// a rune, and fails with ErrBufferTooSmall if p can't hold the next one.
+func WithInvalidUTF8(mode InvalidUTF8Mode) Option {
+	return func(t *TextReader) {
+		t.invalidMode = mode
+	}
+}
+
+// InvalidUTF8 returns the invalid sequences read so far in CollectInvalidUTF8
+// mode, in the order they were found.
+func (t *TextReader) InvalidUTF8() []*InvalidUTF8Error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]*InvalidUTF8Error(nil), t.invalid...)
+}
+
+// nextRune decodes the rune at the read position without consuming it,
+// filling the buffer as needed. Invalid input is handled according to the
+// InvalidUTF8Mode of the reader.
+func (t *TextReader) nextRune() (rune, int, error) {
+	// Try to fill the buffer with at least enough bytes for a maximal rune.
+	// We can tolerate an io.EOF here, as we might have a partial buffer to read from.
+	_, err := t.fillAtLeast(utf8.UTFMax)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, 0, err
+	}
+
+	// If the buffer is empty after trying to fill, we are at the end of the stream.
+	if t.r >= t.w {
+		return 0, 0, io.EOF
+	}
+
+	// Let utf8.DecodeRune handle all cases: valid ASCII, valid multi-byte,
+	// and invalid UTF-8 sequences.
+	// If the sequence is invalid, it returns (utf8.RuneError, 1).
+	r, size := utf8.DecodeRune(t.buf[t.r:t.w])
+	if r != utf8.RuneError || size != 1 || t.invalidMode == ReplaceInvalidUTF8 {
+		return r, size, nil
+	}
+
+	if err := t.invalidRune(); err != nil {
+		return 0, 0, err
+	}
+
+	return r, size, nil
+}
+
+// invalidRune handles the invalid input at the read position according to the
+// InvalidUTF8Mode of the reader, which must not be ReplaceInvalidUTF8. In
+// StrictUTF8 mode it returns the error to stop at.
+func (t *TextReader) invalidRune() error {
+	offset := t.pos.Offset()
+	if t.invalidMode == CollectInvalidUTF8 && offset < t.checked {
+		// Part of a sequence we already know about.
+		return nil
+	}
+
+	seq := t.buf[t.r : t.r+invalidSize(t.buf[t.r:t.w])]
+	e := &InvalidUTF8Error{
+		Bytes: append([]byte(nil), seq...),
+		Pos:   t.pos.Copy(),
+	}
+
+	if t.invalidMode == StrictUTF8 {
+		return e
+	}
+
+	t.invalid = append(t.invalid, e)
+	t.checked = offset + len(seq)
+
+	return nil
+}
+
+// consume moves the read position over the next n buffered bytes, handling
+// invalid input along the way like nextRune does. In StrictUTF8 mode it stops
+// at invalid input, returning the number of bytes it moved over and the error.
+func (t *TextReader) consume(n int) (int, error) {
+	if t.invalidMode == ReplaceInvalidUTF8 {
+		t.scan(t.buf[t.r : t.r+n])
+		t.r += n
+		return n, nil
+	}
+
+	start, end := t.r, t.r+n
+	for t.r < end {
+		i := t.r
+		for i < end {
+			r, size := utf8.DecodeRune(t.buf[i:end])
+			if r == utf8.RuneError && size == 1 {
+				break
+			}
+			i += size
+		}
+
+		t.scan(t.buf[t.r:i])
+		t.r = i
+		if i == end {
+			break
+		}
+
+		if err := t.invalidRune(); err != nil {
+			return t.r - start, err
+		}
+		t.scan(t.buf[t.r : t.r+1])
+		t.r++
+	}
+
+	return n, nil
+}
+
+// readRunes implements Read for the modes that check the input. Unlike Read,
+// it never splits a rune.
+func (t *TextReader) readRunes(p []byte) (int, error) {
+	defer t.history.clear()
+
+	n := 0
+	for n < len(p) {
+		_, size, err := t.nextRune()
+		if err != nil {
+			if n > 0 {
+				break
+			}
+			return 0, err
+		}
+
+		if size > len(p)-n {
+			if n > 0 {
+				break
+			}
+			return 0, ErrBufferTooSmall
+		}
+
+		copy(p[n:], t.buf[t.r:t.r+size])
+		t.scan(p[n : n+size])
+		t.r += size
+		n += size
+	}
+
+	return n, nil
+}
+
+// invalidSize returns the length of the invalid sequence at the start of b:
+// a lead byte along with the continuation bytes after it that could belong to
+// it, or a single byte.
+func invalidSize(b []byte) int {
+	want := 1
+	switch c := b[0]; {
+	case c >= 0xc2 && c <= 0xdf:
+		want = 2
+	case c >= 0xe0 && c <= 0xef:
+		want = 3
+	case c >= 0xf0 && c <= 0xf4:
+		want = 4
+	}
+
+	n := 1
+	for n < want && n < len(b) && !utf8.RuneStart(b[n]) {
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,150 @@
+package textreader
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictUTF8(t *testing.T) {
+	data := "ok\nab\xe6\x97c\xff"
+
+	t.Run("ReadRune", func(t *testing.T) {
+		tr := New(strings.NewReader(data), WithInvalidUTF8(StrictUTF8))
+
+		out := ""
+		for i := 0; i < 5; i++ {
+			r, _, err := tr.ReadRune()
+			require.NoError(t, err)
+			out += string(r)
+		}
+		assert.Equal(t, "ok\nab", out)
+
+		_, _, err := tr.ReadRune()
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrInvalidUTF8)
+
+		var e *InvalidUTF8Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, []byte{0xe6, 0x97}, e.Bytes)
+		assert.Equal(t, 2, e.Pos.Line())
+		assert.Equal(t, 2, e.Pos.Column())
+		assert.Equal(t, 5, e.Pos.Offset())
+		assert.Equal(t, "2:2: invalid UTF-8 encoding: e6 97", e.Error())
+
+		// The reader doesn't move past the invalid sequence.
+		_, _, err = tr.ReadRune()
+		assert.ErrorIs(t, err, ErrInvalidUTF8)
+		assert.Equal(t, 5, tr.Pos().Offset())
+
+		_, err = tr.Seek(int64(len(e.Bytes)), io.SeekCurrent)
+		require.NoError(t, err)
+
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 'c', r)
+
+		_, _, err = tr.ReadRune()
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, []byte{0xff}, e.Bytes)
+		assert.Equal(t, 5, e.Pos.Column())
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		tr := New(strings.NewReader(data), WithInvalidUTF8(StrictUTF8))
+
+		buf := make([]byte, 16)
+		n, err := tr.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, "ok\nab", string(buf[:n]))
+
+		n, err = tr.Read(buf)
+		assert.Equal(t, 0, n)
+		assert.ErrorIs(t, err, ErrInvalidUTF8)
+	})
+
+	t.Run("truncated at the end", func(t *testing.T) {
+		tr := New(strings.NewReader("a\xe6\x97"), WithInvalidUTF8(StrictUTF8))
+
+		_, err := io.ReadAll(tr)
+
+		var e *InvalidUTF8Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, []byte{0xe6, 0x97}, e.Bytes)
+		assert.Equal(t, 1, e.Pos.Offset())
+	})
+
+	t.Run("Read never splits runes", func(t *testing.T) {
+		tr := New(strings.NewReader("a日本"), WithInvalidUTF8(StrictUTF8))
+
+		buf := make([]byte, 5)
+		n, err := tr.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, "a日", string(buf[:n]))
+
+		n, err = tr.Read(buf[:2])
+		assert.Equal(t, 0, n)
+		assert.ErrorIs(t, err, ErrBufferTooSmall)
+
+		n, err = tr.Read(buf[:3])
+		require.NoError(t, err)
+		assert.Equal(t, "本", string(buf[:n]))
+
+		_, err = tr.Read(buf)
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestCollectInvalidUTF8(t *testing.T) {
+	data := "ok\xc3\nab\xe6\x97c\xff\xfe"
+
+	tr := New(strings.NewReader(data), WithInvalidUTF8(CollectInvalidUTF8))
+
+	out, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, data, string(out))
+
+	// Reading again doesn't report the same sequences twice.
+	_, err = tr.Seek(-4, io.SeekCurrent)
+	require.NoError(t, err)
+	for {
+		r, size, err := tr.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if r == utf8.RuneError {
+			assert.Equal(t, 1, size)
+		}
+	}
+
+	invalid := tr.InvalidUTF8()
+	require.Len(t, invalid, 4)
+
+	expected := []struct {
+		bytes  []byte
+		line   int
+		column int
+	}{
+		{[]byte{0xc3}, 1, 2},
+		{[]byte{0xe6, 0x97}, 2, 2},
+		{[]byte{0xff}, 2, 5},
+		{[]byte{0xfe}, 2, 6},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.bytes, invalid[i].Bytes)
+		assert.Equal(t, e.line, invalid[i].Pos.Line())
+		assert.Equal(t, e.column, invalid[i].Pos.Column())
+	}
+
+	// The default mode doesn't collect anything.
+	tr = New(strings.NewReader(data))
+	_, err = io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Empty(t, tr.InvalidUTF8())
+}
@@ -0,0 +1,176 @@
+package textreader
+
+import (
+	"unicode/utf8"
+
+	"github.com/xiam/textreader/position"
+)
+
+// Marker is a checkpoint in the text stream created by Mark. The zero value is
+// not a valid marker.
+type Marker struct {
+	id  uint64
+	pos *position.Position
+}
+
+// Pos returns a copy of the position the marker was created at.
+func (m Marker) Pos() *position.Position {
+	if m.pos == nil {
+		return position.New()
+	}
+	return m.pos.Copy()
+}
+
+type mark struct {
+	id     uint64
+	offset int
+}
+
+// Mark returns a marker for the current read position. While the marker is
+// outstanding the data after it is never discarded from the buffer, so Reset
+// can always return to it. If keeping that data would need a buffer larger
+// than the mark limit (see WithMarkLimit), the oldest outstanding marks are
+// invalidated instead. Use Release to let go of a marker that is no longer
+// needed.
+func (t *TextReader) Mark() Marker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The snapshot must know where the input starts, see sniff.
+	t.sniff()
+
+	id := t.pin(t.pos.Offset())
+
+	return Marker{id: id, pos: t.pos.Copy()}
+}
+
+// Reset moves the reader back (or forward) to the given marker and restores
+// the position it had when the marker was created. The marker remains
+// outstanding, so Reset can be called with it any number of times. It returns
+// ErrInvalidMark if the marker was released or invalidated.
+func (t *TextReader) Reset(m Marker) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := t.findMark(m.id)
+	if i < 0 {
+		return ErrInvalidMark
+	}
+
+	newR := t.marks[i].offset - (t.pos.Offset() - t.r)
+	if newR < 0 || newR > t.w {
+		return ErrInvalidMark
+	}
+
+	t.r = newR
+	t.pos = m.pos.Copy()
+	t.history.clear()
+
+	return nil
+}
+
+// Release lets go of the given marker, allowing the data it pinned to be
+// discarded. Releasing an invalid marker is a no-op.
+func (t *TextReader) Release(m Marker) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.unpin(m.id)
+}
+
+// pin keeps the data after the given offset in the buffer until unpin is
+// called with the returned id, just like an outstanding mark would.
+func (t *TextReader) pin(offset int) uint64 {
+	t.lastMark++
+	t.marks = append(t.marks, mark{id: t.lastMark, offset: offset})
+
+	return t.lastMark
+}
+
+func (t *TextReader) unpin(id uint64) {
+	if i := t.findMark(id); i >= 0 {
+		t.marks = append(t.marks[:i], t.marks[i+1:]...)
+	}
+}
+
+func (t *TextReader) findMark(id uint64) int {
+	if id == 0 {
+		return -1
+	}
+
+	for i := range t.marks {
+		if t.marks[i].id == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// keep returns the index of the first byte in the buffer that can't be
+// discarded, either because it has not been read yet or because it is pinned
+// by a mark.
+func (t *TextReader) keep() int {
+	return t.keepExcept(0)
+}
+
+// keepExcept is like keep, but ignores the mark with the given id.
+func (t *TextReader) keepExcept(id uint64) int {
+	keep := t.r
+
+	start := t.pos.Offset() - t.r
+	for _, m := range t.marks {
+		if i := m.offset - start; m.id != id && i >= 0 && i < keep {
+			keep = i
+		}
+	}
+
+	return keep
+}
+
+// dropsMarks reports whether filling the buffer with n bytes ahead of the read
+// position would invalidate any mark other than the one with the given id, see
+// fillAtLeast and compact.
+func (t *TextReader) dropsMarks(n int, id uint64) bool {
+	if t.w-t.r >= n || t.r+n < len(t.buf) {
+		return false
+	}
+
+	keep := t.keepExcept(id)
+	return keep < t.r && t.r-keep+n > t.markLimit
+}
+
+// behind returns the index of the first byte in the buffer that is part of the
+// look-behind window, see WithLookBehind.
+func (t *TextReader) behind() int {
+	if t.lookBehind == 0 {
+		return t.r
+	}
+
+	i := max(t.r-t.lookBehind, 0)
+	for i > 0 && !utf8.RuneStart(t.buf[i]) {
+		i--
+	}
+
+	if start := t.pos.LineEndings().LastLineStart(t.buf[:i]); start >= 0 && i-start <= t.lookBehind {
+		i = start
+	}
+
+	return i
+}
+
+// dropMark invalidates the mark that pins the oldest data.
+func (t *TextReader) dropMark() {
+	if len(t.marks) == 0 {
+		return
+	}
+
+	oldest := 0
+	for i := range t.marks {
+		if t.marks[i].offset < t.marks[oldest].offset {
+			oldest = i
+		}
+	}
+
+	t.marks = append(t.marks[:oldest], t.marks[oldest+1:]...)
+}
@@ -0,0 +1,157 @@
+package textreader
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMark(t *testing.T) {
+	data := "first line\nsecond line\nthird line\nfourth line\nfifth line 🦄\n"
+
+	t.Run("reset within buffer", func(t *testing.T) {
+		tr := New(strings.NewReader(data))
+
+		buf := make([]byte, 6)
+		_, err := io.ReadFull(tr, buf)
+		require.NoError(t, err)
+
+		m := tr.Mark()
+		assert.Equal(t, 6, m.Pos().Offset())
+
+		rest, err := readAllRunes(tr)
+		require.NoError(t, err)
+		assert.Equal(t, data[6:], rest)
+
+		require.NoError(t, tr.Reset(m))
+
+		pos := tr.Pos()
+		assert.Equal(t, 1, pos.Line())
+		assert.Equal(t, 6, pos.Column())
+		assert.Equal(t, 6, pos.Offset())
+
+		// The marker can be used more than once.
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 'l', r)
+
+		require.NoError(t, tr.Reset(m))
+		assert.Equal(t, 6, tr.Pos().Offset())
+
+		tr.Release(m)
+		assert.ErrorIs(t, tr.Reset(m), ErrInvalidMark)
+	})
+
+	t.Run("pins data across refills", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader(data), 8, WithMarkLimit(64))
+
+		_, err := io.ReadFull(tr, make([]byte, 14))
+		require.NoError(t, err)
+
+		m := tr.Mark()
+
+		// Read far beyond the capacity of the buffer.
+		buf := make([]byte, 30)
+		_, err = io.ReadFull(tr, buf)
+		require.NoError(t, err)
+		assert.Equal(t, data[14:44], string(buf))
+		assert.Greater(t, len(tr.buf), 8)
+
+		pos := tr.Pos()
+		assert.Equal(t, 4, pos.Line())
+		assert.Equal(t, 44, pos.Offset())
+
+		require.NoError(t, tr.Reset(m))
+
+		pos = tr.Pos()
+		assert.Equal(t, 2, pos.Line())
+		assert.Equal(t, 3, pos.Column())
+		assert.Equal(t, 14, pos.Offset())
+
+		rest, err := readAllRunes(tr)
+		require.NoError(t, err)
+		assert.Equal(t, data[14:], rest)
+
+		// Once released, the buffer goes back to its original capacity on the
+		// next refill.
+		tr.Release(m)
+
+		tr = NewWithCapacity(strings.NewReader(data), 8, WithMarkLimit(64))
+		m = tr.Mark()
+		_, err = io.ReadFull(tr, make([]byte, 20))
+		require.NoError(t, err)
+		assert.Greater(t, len(tr.buf), 8)
+
+		tr.Release(m)
+		_, err = io.ReadFull(tr, make([]byte, 20))
+		require.NoError(t, err)
+		assert.Equal(t, 8, len(tr.buf))
+	})
+
+	t.Run("seek forward after reset", func(t *testing.T) {
+		// A plain stream, so that seeking can't fall back on the source.
+		tr := NewWithCapacity(struct{ io.Reader }{strings.NewReader(data)}, 8, WithMarkLimit(64))
+
+		m := tr.Mark()
+		_, err := io.ReadFull(tr, make([]byte, 30))
+		require.NoError(t, err)
+		require.NoError(t, tr.Reset(m))
+
+		// Every byte up to the target is still buffered.
+		offset, err := tr.Seek(30, io.SeekStart)
+		require.NoError(t, err)
+		assert.Equal(t, int64(30), offset)
+		assert.Equal(t, 3, tr.Pos().Line())
+		assert.Equal(t, 7, tr.Pos().Column())
+	})
+
+	t.Run("nested marks", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader(data), 8, WithMarkLimit(128))
+
+		outer := tr.Mark()
+		_, err := io.ReadFull(tr, make([]byte, 11))
+		require.NoError(t, err)
+
+		inner := tr.Mark()
+		_, err = io.ReadFull(tr, make([]byte, 20))
+		require.NoError(t, err)
+
+		require.NoError(t, tr.Reset(inner))
+		assert.Equal(t, 2, tr.Pos().Line())
+		assert.Equal(t, 0, tr.Pos().Column())
+
+		tr.Release(inner)
+
+		require.NoError(t, tr.Reset(outer))
+		assert.Equal(t, 1, tr.Pos().Line())
+		assert.Equal(t, 0, tr.Pos().Offset())
+
+		out, err := readAllRunes(tr)
+		require.NoError(t, err)
+		assert.Equal(t, data, out)
+	})
+
+	t.Run("exceeding the mark limit invalidates the mark", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader(data), 8)
+
+		m := tr.Mark()
+
+		_, err := io.ReadFull(tr, make([]byte, 20))
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, tr.Reset(m), ErrInvalidMark)
+		assert.Equal(t, 8, len(tr.buf))
+		assert.Equal(t, 20, tr.Pos().Offset())
+	})
+
+	t.Run("zero marker", func(t *testing.T) {
+		tr := New(strings.NewReader(data))
+
+		assert.ErrorIs(t, tr.Reset(Marker{}), ErrInvalidMark)
+		tr.Release(Marker{})
+		assert.Equal(t, 0, Marker{}.Pos().Offset())
+	})
+}
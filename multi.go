@@ -0,0 +1,128 @@
+package textreader
+
+import (
+	"io"
+
+	"github.com/xiam/textreader/position"
+)
+
+// Source is a named input, see MultiReader.
+type Source struct {
+	// Name is the name of the source, usually a file name.
+	Name string
+
+	// R is where the text of the source is read from.
+	R io.Reader
+}
+
+// multiReader is the reader returned by MultiReader.
+type multiReader struct {
+	sources []Source
+	i       int // source being read
+	last    int // source the bytes returned by the last Read came from
+}
+
+// MultiReader returns a reader that is the concatenation of the given sources,
+// like io.MultiReader. A TextReader reading from it starts lines and columns
+// over at the beginning of every source, and reports the name of the current
+// one in its position, see position.Position.Restart. Source boundaries are
+// not tracked if the TextReader decodes its input, see WithEncoding.
+func MultiReader(sources ...Source) io.Reader {
+	return &multiReader{sources: append([]Source(nil), sources...)}
+}
+
+func (m *multiReader) Read(p []byte) (int, error) {
+	for m.i < len(m.sources) {
+		n, err := m.sources[m.i].R.Read(p)
+		m.last = m.i
+		if err != io.EOF {
+			return n, err
+		}
+
+		m.i++
+		if n > 0 {
+			return n, nil
+		}
+	}
+
+	return 0, io.EOF
+}
+
+// NewNamed returns a new TextReader that reads from r, a source with the given
+// name, usually a file name. The name is reported by the reader's position
+// and its errors.
+func NewNamed(name string, r io.Reader, opts ...Option) *TextReader {
+	return New(r, append([]Option{WithPositionOptions(position.WithName(name))}, opts...)...)
+}
+
+// boundary is the offset at which a source of a MultiReader starts.
+type boundary struct {
+	offset int
+	name   string
+}
+
+// readSource reads from the underlying reader into p, which is where the bytes
+// at the given offset go, keeping track of the boundaries between sources.
+func (t *TextReader) readSource(p []byte, offset int) (int, error) {
+	n, err := t.br.Read(p)
+
+	if t.multi != nil && n > 0 {
+		for t.source < t.multi.last {
+			t.source++
+			t.boundaries = append(t.boundaries, boundary{offset: offset, name: t.multi.sources[t.source].Name})
+		}
+	}
+
+	return n, err
+}
+
+// scan moves the position over b, the bytes at the read position, starting
+// over at every source boundary within them.
+func (t *TextReader) scan(b []byte) {
+	// Once the input is over we know its size, which the decoded text can't
+	// always tell, see position.SetSourceSize.
+	if t.dec != nil && t.dec.err != nil {
+		t.pos.SetSourceSize(t.dec.size)
+	}
+
+	for _, bd := range t.boundaries {
+		at := bd.offset - t.pos.Offset()
+		if at < 0 {
+			continue
+		}
+		if at >= len(b) {
+			break
+		}
+
+		t.pos.Scan(b[:at])
+		t.pos.Restart(bd.name)
+		b = b[at:]
+	}
+
+	t.scanLines(b)
+}
+
+// nextBoundary returns the index in the buffer of the first source boundary
+// at or after the read position, or -1 if there is none in the buffer.
+func (t *TextReader) nextBoundary() int {
+	offset := t.pos.Offset()
+	for _, bd := range t.boundaries {
+		if bd.offset >= offset {
+			return t.r + bd.offset - offset
+		}
+	}
+
+	return -1
+}
+
+// dropBoundaries forgets the boundaries before the given offset, which won't
+// be scanned again.
+func (t *TextReader) dropBoundaries(offset int) {
+	i := 0
+	for i < len(t.boundaries) && t.boundaries[i].offset < offset {
+		i++
+	}
+
+	n := copy(t.boundaries, t.boundaries[i:])
+	t.boundaries = t.boundaries[:n]
+}
@@ -0,0 +1,164 @@
+package textreader
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNamed(t *testing.T) {
+	tr := NewNamed("config.txt", strings.NewReader("a = 1\nb = 2\n"))
+	assert.Equal(t, "config.txt", tr.Pos().Name())
+
+	_, err := io.ReadFull(tr, make([]byte, 8))
+	require.NoError(t, err)
+	assert.Equal(t, "config.txt:2:2", tr.Pos().String())
+
+	_, err = tr.Seek(-20, io.SeekCurrent)
+
+	var e *PosError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "config.txt:2:2: seek: textreader: negative position", err.Error())
+}
+
+func TestMultiReader(t *testing.T) {
+	newSources := func() []Source {
+		return []Source{
+			{Name: "main.c", R: strings.NewReader("int x;\n#include")},
+			{Name: "empty.h", R: strings.NewReader("")},
+			{Name: "header.h", R: strings.NewReader("int y;\nint z;\n")},
+			{Name: "main.c", R: strings.NewReader("\nint w;")},
+		}
+	}
+
+	expected := []string{}
+	for _, s := range newSources() {
+		b, _ := io.ReadAll(s.R)
+		expected = append(expected, string(b))
+	}
+	text := strings.Join(expected, "")
+
+	readers := map[string]func([]Source) io.Reader{
+		"plain": func(s []Source) io.Reader { return MultiReader(s...) },
+		"one byte": func(s []Source) io.Reader {
+			for i := range s {
+				s[i].R = iotest.OneByteReader(s[i].R)
+			}
+			return MultiReader(s...)
+		},
+	}
+
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			tr := NewWithCapacity(newReader(newSources()), 8)
+			assert.Equal(t, "main.c:1:0", tr.Pos().String())
+
+			var positions []string
+			out := ""
+			for {
+				r, _, err := tr.ReadRune()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				out += string(r)
+				positions = append(positions, tr.Pos().String())
+			}
+			assert.Equal(t, text, out)
+
+			assert.Equal(t, "main.c:2:1", positions[7])
+			assert.Equal(t, "main.c:2:8", positions[14])
+			assert.Equal(t, "header.h:1:1", positions[15])
+			assert.Equal(t, "header.h:3:0", positions[28])
+			assert.Equal(t, "main.c:2:0", positions[29])
+			assert.Equal(t, "main.c:2:6", tr.Pos().String())
+			assert.Equal(t, 7, tr.Pos().SourceOffset())
+		})
+	}
+
+	t.Run("seek across sources", func(t *testing.T) {
+		tr := New(MultiReader(newSources()...))
+
+		_, err := io.ReadAll(tr)
+		require.NoError(t, err)
+
+		// Right at the start of a source is the end of the previous one.
+		_, err = tr.Seek(-7, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, "header.h:3:0", tr.Pos().String())
+		assert.Equal(t, 14, tr.Pos().SourceOffset())
+
+		_, err = tr.Seek(-16, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, "main.c:2:6", tr.Pos().String())
+
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 'd', r)
+
+		_, err = tr.Seek(3, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, "header.h:1:2", tr.Pos().String())
+
+		r, _, err = tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 't', r)
+
+		r, _, err = tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, ' ', r)
+	})
+}
+
+func TestMultiReader_Read(t *testing.T) {
+	tr := New(MultiReader(
+		Source{Name: "a", R: strings.NewReader("1\n2\n")},
+		Source{Name: "b", R: strings.NewReader("3")},
+	))
+
+	out, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n3", string(out))
+	assert.Equal(t, "b:1:1", tr.Pos().String())
+
+	// Reads larger than the buffer go straight to the sources.
+	tr = NewWithCapacity(MultiReader(
+		Source{Name: "a", R: strings.NewReader("1\n2\n")},
+		Source{Name: "b", R: strings.NewReader("3\n4")},
+	), 4)
+
+	buf := make([]byte, 16)
+	n, err := io.ReadFull(tr, buf[:7])
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n3\n4", string(buf[:n]))
+	assert.Equal(t, "b:2:1", tr.Pos().String())
+}
+
+func TestMultiReader_CurrentLine(t *testing.T) {
+	tr := New(MultiReader(
+		Source{Name: "a", R: strings.NewReader("first\nsecond")},
+		Source{Name: "b", R: strings.NewReader("third\n")},
+	))
+
+	_, err := io.ReadFull(tr, make([]byte, 8))
+	require.NoError(t, err)
+
+	text, column, err := tr.CurrentLine()
+	require.NoError(t, err)
+	assert.Equal(t, "second", text)
+	assert.Equal(t, 2, column)
+
+	_, err = io.ReadFull(tr, make([]byte, 5))
+	require.NoError(t, err)
+	assert.Equal(t, "b:1:1", tr.Pos().String())
+
+	text, column, err = tr.CurrentLine()
+	require.NoError(t, err)
+	assert.Equal(t, "third", text)
+	assert.Equal(t, 1, column)
+}
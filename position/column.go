@@ -0,0 +1,149 @@
+package position
+
+import (
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// ColumnMode selects the unit Column and VisualColumn count in.
+type ColumnMode int
+
+const (
+	// Runes counts Unicode code points. This is the default.
+	Runes ColumnMode = iota
+
+	// Graphemes counts user-perceived characters, as delimited by the grapheme
+	// cluster boundaries of UAX #29. A letter followed by combining marks, or
+	// an emoji ZWJ sequence, counts as one.
+	Graphemes
+
+	// Cells counts the cells the text takes up on a monospace display,
+	// following UAX #11 (East Asian Width). Full-width characters and most
+	// emoji take two cells, combining marks and control characters take none.
+	Cells
+)
+
+// regularRune stands in for any rune that advances all columns by exactly
+// one and starts a grapheme cluster of its own. Such runes don't need a stop,
+// and which one it was doesn't matter for segmenting the runes after it.
+const regularRune = 'a'
+
+// maxClusterSize bounds the bytes of a grapheme cluster we look at. Runs of
+// combining marks can be arbitrarily long and we don't want scanning them to
+// take quadratic time.
+const maxClusterSize = 64
+
+// WithColumnMode sets what Column and VisualColumn count in. The default is
+// Runes.
+func WithColumnMode(mode ColumnMode) Option {
+	return func(p *Position) {
+		p.mode = mode
+	}
+}
+
+// advance updates the current line after scanning the rune r, encoded as b,
+// which is not a line break.
+func (p *Position) advance(r rune, b []byte) {
+	l := &p.lines[len(p.lines)-1]
+	l.runes++
+	l.bytes += len(b)
+	l.src += p.sourceSize(r, len(b))
+	l.u16 += utf16Len(r)
+
+	if r == tab {
+		tw := p.tabWidth
+		if tw < 1 {
+			tw = defaultTabWidth
+		}
+
+		p.cluster, p.clusterWidth, p.clusterCol = append(p.cluster[:0], b...), 0, l.cols
+
+		l.cols++
+		l.visual += tw - l.visual%tw
+
+		p.addStop()
+		return
+	}
+
+	if p.mode == Runes {
+		p.clusterCol = l.cols
+		l.cols++
+		l.visual++
+
+		if r == carriageReturn && p.endings == CRLF || r > maxBMP {
+			// Leave a stop so Scan can tell whether a "\n" completes a "\r\n",
+			// and so Rewind can tell the size of the rune in UTF-16.
+			p.cluster, p.clusterWidth = append(p.cluster[:0], b...), 0
+			p.addStop()
+		}
+		return
+	}
+
+	boundary, width := p.extendCluster(b)
+
+	delta := width
+	if p.mode == Graphemes {
+		delta = 0
+		if boundary {
+			delta = 1
+		}
+	}
+
+	l.cols += delta
+	l.visual += delta
+
+	if !boundary || delta != 1 || r < ' ' || r > '~' {
+		p.addStop()
+	}
+}
+
+// extendCluster adds b to the grapheme cluster being scanned. It reports
+// whether b starts a new cluster and how much the width of the current
+// cluster changed.
+func (p *Position) extendCluster(b []byte) (bool, int) {
+	if n := len(p.cluster); n > 0 {
+		seg := append(p.cluster, b...)
+		if n >= maxClusterSize {
+			// Only look at the last rune of a long cluster.
+			_, size := utf8.DecodeLastRune(p.cluster)
+			seg = append(append([]byte(nil), p.cluster[n-size:]...), b...)
+		}
+
+		_, rest, width, _ := uniseg.FirstGraphemeCluster(seg, -1)
+		if len(rest) == 0 {
+			if n >= maxClusterSize {
+				return false, 0
+			}
+
+			delta := width - p.clusterWidth
+			p.cluster, p.clusterWidth = seg, width
+			return false, delta
+		}
+	}
+
+	_, _, width, _ := uniseg.FirstGraphemeCluster(b, -1)
+	p.cluster, p.clusterWidth = append(p.cluster[:0], b...), width
+	p.clusterCol = p.lines[len(p.lines)-1].cols
+
+	return true, width
+}
+
+// addStop records the state right after the last rune scanned.
+func (p *Position) addStop() {
+	zl := len(p.lines) - 1
+	l := p.lines[zl]
+
+	p.stops = append(p.stops, stop{
+		line:    p.base + zl,
+		runes:   l.runes,
+		bytes:   l.bytes,
+		cols:    l.cols,
+		visual:  l.visual,
+		cluster: string(p.cluster),
+		width:   p.clusterWidth,
+		start:   p.clusterCol,
+		src:     l.src,
+		u16:     l.u16,
+	})
+}
@@ -0,0 +1,122 @@
+package position_test
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+func TestColumnModes(t *testing.T) {
+	testCases := []struct {
+		name      string
+		text      string
+		runes     int
+		graphemes int
+		cells     int
+	}{
+		{"ascii", "hello", 5, 5, 5},
+		{"combining marks", "été", 5, 3, 3},
+		{"emoji", "🦄!", 2, 2, 3},
+		{"emoji ZWJ sequence", "👩‍👩‍👧‍👦", 7, 1, 2},
+		{"emoji modifier", "👍🏽 ok", 5, 4, 5},
+		{"flags", "🇯🇵🇺🇸", 4, 2, 4},
+		{"CJK", "日本語", 3, 3, 6},
+		{"full-width", "ＡＢ", 2, 2, 4},
+		{"hangul jamo", "각", 3, 1, 2},
+		{"mixed", "a日b́🦄", 5, 4, 6},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for mode, expected := range map[position.ColumnMode]int{
+				position.Runes:     tc.runes,
+				position.Graphemes: tc.graphemes,
+				position.Cells:     tc.cells,
+			} {
+				p := position.New(position.WithColumnMode(mode))
+				p.Scan([]byte(tc.text))
+				assert.Equal(t, expected, p.Column(), "mode %d", mode)
+				assert.Equal(t, expected, p.VisualColumn(), "mode %d", mode)
+				assert.Equal(t, len(tc.text), p.Offset())
+
+				// Scanning rune by rune gives the same result.
+				p = position.New(position.WithColumnMode(mode))
+				for _, r := range tc.text {
+					p.Scan([]byte(string(r)))
+				}
+				assert.Equal(t, expected, p.Column(), "mode %d", mode)
+			}
+		})
+	}
+}
+
+func TestColumnModes_Tabs(t *testing.T) {
+	p := position.New(position.WithColumnMode(position.Cells), position.WithTabWidth(4))
+	p.Scan([]byte("日\t本\t"))
+	assert.Equal(t, 6, p.Column())
+	assert.Equal(t, 8, p.VisualColumn())
+
+	p = position.New(position.WithColumnMode(position.Graphemes), position.WithTabWidth(4))
+	p.Scan([]byte("é\tx"))
+	assert.Equal(t, 3, p.Column())
+	assert.Equal(t, 5, p.VisualColumn())
+}
+
+// TestColumnModes_Rewind checks that rewinding to every rune boundary gives
+// the same columns as scanning up to that boundary.
+func TestColumnModes_Rewind(t *testing.T) {
+	texts := []string{
+		"áb 日本\tx",
+		"👩‍👩‍👧 and 👍🏽\n🇯🇵🇺🇸 ok",
+		"line\n\t각 한\né̂̃!",
+		"ab‍c🦄︎🦄️d",
+	}
+
+	for _, text := range texts {
+		for _, mode := range []position.ColumnMode{position.Runes, position.Graphemes, position.Cells} {
+			full := position.New(position.WithColumnMode(mode), position.WithTabWidth(4))
+			full.Scan([]byte(text))
+
+			for i := len(text); i >= 0; i-- {
+				if i < len(text) && !utf8.RuneStart(text[i]) {
+					continue
+				}
+
+				expected := position.New(position.WithColumnMode(mode), position.WithTabWidth(4))
+				expected.Scan([]byte(text[:i]))
+
+				p := full.Copy()
+				err := p.Rewind(len(text)-i, utf8.RuneCountInString(text[i:]))
+				require.NoError(t, err)
+
+				assert.Equal(t, expected.Line(), p.Line(), "%q mode %d at %d", text, mode, i)
+				assert.Equal(t, expected.Column(), p.Column(), "%q mode %d at %d", text, mode, i)
+				assert.Equal(t, expected.VisualColumn(), p.VisualColumn(), "%q mode %d at %d", text, mode, i)
+
+				// Scanning the rest of the text again must end up where we started.
+				p.Scan([]byte(text[i:]))
+				assert.Equal(t, full.Column(), p.Column(), "%q mode %d at %d", text, mode, i)
+				assert.Equal(t, full.VisualColumn(), p.VisualColumn(), "%q mode %d at %d", text, mode, i)
+			}
+		}
+	}
+}
+
+func TestColumnModes_LongCluster(t *testing.T) {
+	text := "a"
+	for i := 0; i < 1000; i++ {
+		text += "́"
+	}
+	text += "b"
+
+	p := position.New(position.WithColumnMode(position.Graphemes))
+	p.Scan([]byte(text))
+	assert.Equal(t, 2, p.Column())
+
+	p = position.New(position.WithColumnMode(position.Cells))
+	p.Scan([]byte(text))
+	assert.Equal(t, 2, p.Column())
+}
@@ -0,0 +1,88 @@
+package position
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// maxBMP is the last rune of the Basic Multilingual Plane, the runes after it
+// take two UTF-16 code units.
+const maxBMP = 0xffff
+
+// Encoding selects the unit of ColumnIn: UTF-8 bytes, UTF-16 code units or
+// code points. These are the position encodings of the Language Server
+// Protocol, where UTF16 is the default.
+type Encoding int
+
+const (
+	// UTF8 counts bytes.
+	UTF8 Encoding = iota
+
+	// UTF16 counts UTF-16 code units: two for runes outside the Basic
+	// Multilingual Plane, like most emoji, and one for every other rune.
+	UTF16
+
+	// UTF32 counts code points, like Column does in Runes mode.
+	UTF32
+)
+
+// ColumnIn returns the column in the given encoding, counting from 0. Unlike
+// Column, it doesn't depend on the column mode of the position. Invalid UTF-8
+// counts as one code point, or code unit, per byte.
+func (p *Position) ColumnIn(enc Encoding) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	zl := len(p.lines)
+	if zl == 0 {
+		return 0
+	}
+
+	l := p.lines[zl-1]
+	switch enc {
+	case UTF8:
+		return l.bytes
+	case UTF16:
+		return l.u16
+	}
+
+	return l.runes
+}
+
+// ConvertColumn converts col, a column on the given line of text, from one
+// encoding to another. It fails if col is past the end of the text, or falls
+// in the middle of a rune.
+func ConvertColumn(text []byte, col int, from, to Encoding) (int, error) {
+	if col < 0 {
+		return 0, fmt.Errorf("invalid column %d", col)
+	}
+	if from < UTF8 || from > UTF32 || to < UTF8 || to > UTF32 {
+		return 0, fmt.Errorf("invalid encoding")
+	}
+
+	var n [3]int // column in every encoding
+	for n[from] < col {
+		if n[UTF8] == len(text) {
+			return 0, fmt.Errorf("column %d is past the end of the line", col)
+		}
+
+		r, size := utf8.DecodeRune(text[n[UTF8]:])
+		n[UTF8] += size
+		n[UTF16] += utf16Len(r)
+		n[UTF32]++
+	}
+
+	if n[from] != col {
+		return 0, fmt.Errorf("column %d is in the middle of a character", col)
+	}
+
+	return n[to], nil
+}
+
+// utf16Len returns the number of UTF-16 code units of r.
+func utf16Len(r rune) int {
+	if r > maxBMP {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,91 @@
+package position_test
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+func TestColumnIn(t *testing.T) {
+	p := position.New()
+	p.Scan([]byte("x\naé🦄\tb"))
+
+	assert.Equal(t, 5, p.Column())
+	assert.Equal(t, 5, p.ColumnIn(position.UTF32))
+	assert.Equal(t, 6, p.ColumnIn(position.UTF16))
+	assert.Equal(t, 9, p.ColumnIn(position.UTF8))
+
+	assert.Equal(t, 0, position.New().ColumnIn(position.UTF16))
+}
+
+// TestColumnIn_Rewind checks that rewinding to every rune boundary gives the
+// same columns as scanning up to it.
+func TestColumnIn_Rewind(t *testing.T) {
+	text := "a🦄\r\nñ\tb🦄🦄\r\u0301c 日\xff🦄"
+	encodings := []position.Encoding{position.UTF8, position.UTF16, position.UTF32}
+
+	for _, endings := range []position.LineEndings{position.LF, position.CRLF, position.Unicode} {
+		for _, mode := range []position.ColumnMode{position.Runes, position.Graphemes, position.Cells} {
+			opts := []position.Option{position.WithLineEndings(endings), position.WithColumnMode(mode)}
+
+			full := position.New(opts...)
+			full.Scan([]byte(text))
+
+			for i := len(text); i >= 0; i-- {
+				if i < len(text) && !utf8.RuneStart(text[i]) {
+					continue
+				}
+
+				expected := position.New(opts...)
+				expected.Scan([]byte(text[:i]))
+
+				p := full.Copy()
+				require.NoError(t, p.Rewind(len(text)-i, utf8.RuneCountInString(text[i:])))
+
+				for _, enc := range encodings {
+					assert.Equal(t, expected.ColumnIn(enc), p.ColumnIn(enc), "endings %d mode %d encoding %d at %d", endings, mode, enc, i)
+				}
+			}
+		}
+	}
+}
+
+func TestConvertColumn(t *testing.T) {
+	line := []byte("aé🦄b")
+
+	testCases := []struct {
+		col      int
+		from, to position.Encoding
+		expected int
+	}{
+		{0, position.UTF16, position.UTF8, 0},
+		{2, position.UTF16, position.UTF8, 3},
+		{4, position.UTF16, position.UTF8, 7},
+		{5, position.UTF16, position.UTF8, 8},
+		{4, position.UTF16, position.UTF32, 3},
+		{7, position.UTF8, position.UTF16, 4},
+		{3, position.UTF32, position.UTF16, 4},
+		{4, position.UTF32, position.UTF8, 8},
+	}
+
+	for _, tc := range testCases {
+		col, err := position.ConvertColumn(line, tc.col, tc.from, tc.to)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, col, "column %d from %d to %d", tc.col, tc.from, tc.to)
+	}
+
+	_, err := position.ConvertColumn(line, 3, position.UTF16, position.UTF8)
+	assert.Error(t, err, "in the middle of a surrogate pair")
+
+	_, err = position.ConvertColumn(line, 2, position.UTF8, position.UTF16)
+	assert.Error(t, err, "in the middle of a rune")
+
+	_, err = position.ConvertColumn(line, 6, position.UTF16, position.UTF8)
+	assert.Error(t, err, "past the end")
+
+	_, err = position.ConvertColumn(line, -1, position.UTF16, position.UTF8)
+	assert.Error(t, err)
+}
@@ -0,0 +1,365 @@
+package position
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrNoSource is returned by Index lookups that need to scan text again but
+// have no source to read it from, see Index.SetSource.
+var ErrNoSource = errors.New("position: index has no source")
+
+// ErrIndexLoaded is returned by Index.Scan on an index read by UnmarshalBinary,
+// which doesn't keep what's needed to carry on scanning.
+var ErrIndexLoaded = errors.New("position: index was read by UnmarshalBinary")
+
+// indexVersion is the version of the format written by Index.MarshalBinary.
+const indexVersion = 1
+
+// Index records where lines start as text is scanned, so that byte offsets
+// can be translated into lines and columns and back in O(log n) time, without
+// scanning the text again from the start.
+//
+// To save memory the index can record only one line start every few lines.
+// Lookups then scan the lines in between again, reading them from the source
+// set with SetSource. Columns are counted like Column counts them, which also
+// takes the text of the line: without a source, an index that records every
+// line start counts columns in bytes.
+type Index struct {
+	mu sync.Mutex
+
+	pos    *Position   // scans the text, only the last lines are kept
+	every  int         // a line start is recorded every this many lines
+	starts []int       // starts[i] is the offset at which line i*every starts, counting from 0
+	last   int         // index of the last line whose start was recorded or skipped, counting from 0
+	src    io.ReaderAt // text to scan again, may be nil
+	loaded bool        // whether the index was read by UnmarshalBinary
+}
+
+// NewIndex returns an index that records the start of one line every the
+// given number of lines. The options configure how lines and columns are
+// counted, as they do for New.
+func NewIndex(every int, opts ...Option) *Index {
+	return &Index{
+		pos:    New(opts...),
+		every:  max(every, 1),
+		starts: []int{0},
+	}
+}
+
+// SetSource sets the text the index scans again to answer lookups, which must
+// be the same text that was scanned to build it.
+func (ix *Index) SetSource(src io.ReaderAt) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.src = src
+}
+
+// Scan adds the given text to the index, see Position.Scan. It returns
+// ErrIndexLoaded if the index was read by UnmarshalBinary, build a new index
+// to scan more text.
+func (ix *Index) Scan(in []byte) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.loaded {
+		return ErrIndexLoaded
+	}
+
+	p := ix.pos
+	p.Scan(in)
+
+	p.mu.Lock()
+	// The start of the current line might still move, as in a "\r" followed by
+	// a "\n" that wasn't scanned yet, so only the lines before it count.
+	start := p.origin
+	for i := 0; i < len(p.lines)-2; i++ {
+		eolBytes, _ := p.lines[i].eol.size()
+		start += p.lines[i].bytes + eolBytes
+
+		if n := p.base + i + 1; n > ix.last {
+			if n%ix.every == 0 {
+				ix.starts = append(ix.starts, start)
+			}
+			ix.last = n
+		}
+	}
+	lineStart := p.offset - p.lines[len(p.lines)-1].bytes
+	p.mu.Unlock()
+
+	// Keep the line before the current one, a "\n" could still join the "\r"
+	// it ends with.
+	p.Discard(lineStart - 1)
+
+	return nil
+}
+
+// Len returns the number of bytes scanned.
+func (ix *Index) Len() int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	return ix.pos.Offset()
+}
+
+// Lines returns the number of lines scanned.
+func (ix *Index) Lines() int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	return ix.pos.Line()
+}
+
+// LineCol returns the line and column at the given byte offset, counting
+// lines from 1 and columns from 0 like Position does.
+func (ix *Index) LineCol(offset int) (int, int, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if offset < 0 || offset > ix.pos.Offset() {
+		return 0, 0, fmt.Errorf("offset %d out of range [0, %d]", offset, ix.pos.Offset())
+	}
+
+	n, start := ix.lineBefore(offset)
+
+	if ix.src == nil {
+		if ix.every > 1 {
+			return 0, 0, ErrNoSource
+		}
+		return n + 1, offset - start, nil
+	}
+
+	text, err := ix.read(start, offset)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	p := ix.at(n, start)
+	p.Scan(text)
+
+	return p.Line(), p.Column(), nil
+}
+
+// Offset returns the byte offset of the given line and column, counting lines
+// from 1 and columns from 0 like Position does. If a character spans several
+// columns, the offset of the first one is returned.
+func (ix *Index) Offset(line, col int) (int, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if line < 1 || line > ix.pos.Line() || col < 0 {
+		return 0, fmt.Errorf("line %d, column %d out of range", line, col)
+	}
+
+	n, start := ix.lineStart(line - 1)
+	end, last := ix.pos.Offset(), true
+	if i := n/ix.every + 1; i < len(ix.starts) {
+		end, last = ix.starts[i], false
+	}
+
+	if ix.src == nil {
+		if ix.every > 1 {
+			return 0, ErrNoSource
+		}
+		// Without the text we don't know how long the line break is, but
+		// the next line starts after it.
+		if col > end-start || col == end-start && !last {
+			return 0, fmt.Errorf("line %d, column %d out of range", line, col)
+		}
+		return start + col, nil
+	}
+
+	text, err := ix.read(start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	p := ix.at(n, start)
+	found, before := -1, -1
+	for i := 0; ; {
+		if p.Line() == line && p.Column() <= col {
+			// The character that starts here might span the column.
+			before = start + i
+			if p.Column() == col {
+				found = before
+			}
+		}
+		if p.Line() == line && p.Column() > col {
+			found = before
+			break
+		}
+		if i == len(text) || p.Line() > line {
+			break
+		}
+
+		_, size := utf8.DecodeRune(text[i:])
+		p.Scan(text[i : i+size])
+		i += size
+	}
+
+	if found < 0 {
+		return 0, fmt.Errorf("line %d, column %d out of range", line, col)
+	}
+
+	return found, nil
+}
+
+// lineBefore returns the index, counting from 0, and the start of the last
+// line at or before the given offset that the index knows where it starts.
+func (ix *Index) lineBefore(offset int) (int, int) {
+	if n := ix.pos.Line() - 1; n%ix.every == 0 {
+		if start := ix.pos.LineStart(); start <= offset {
+			return n, start
+		}
+	}
+
+	i := sort.Search(len(ix.starts), func(i int) bool {
+		return ix.starts[i] > offset
+	}) - 1
+
+	return i * ix.every, ix.starts[i]
+}
+
+// lineStart returns the index, counting from 0, and the start of the last line
+// at or before line n that the index knows where it starts.
+func (ix *Index) lineStart(n int) (int, int) {
+	if cur := ix.pos.Line() - 1; n == cur && n%ix.every == 0 {
+		return n, ix.pos.LineStart()
+	}
+
+	i := n / ix.every
+	return i * ix.every, ix.starts[i]
+}
+
+// at returns a position at the start of the n-th line, counting from 0, which
+// starts at the given offset.
+func (ix *Index) at(n, offset int) *Position {
+	return &Position{
+		base:     n,
+		origin:   offset,
+		offset:   offset,
+		tabWidth: ix.pos.tabWidth,
+		mode:     ix.pos.mode,
+		endings:  ix.pos.endings,
+	}
+}
+
+// read returns the text between the given offsets.
+func (ix *Index) read(from, to int) ([]byte, error) {
+	text := make([]byte, to-from)
+
+	n, err := ix.src.ReadAt(text, int64(from))
+	if n == len(text) {
+		return text, nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return nil, err
+}
+
+// MarshalBinary encodes the index, so it can be reused for the same text
+// without scanning it again. Sources are not encoded.
+func (ix *Index) MarshalBinary() ([]byte, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	p := ix.pos
+	b := []byte{indexVersion}
+	header := []int{ix.every, p.tabWidth, int(p.mode), int(p.endings), p.Offset(), p.Line() - 1, p.LineStart(), len(ix.starts)}
+	for _, v := range header {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+
+	prev := 0
+	for _, start := range ix.starts {
+		b = binary.AppendUvarint(b, uint64(start-prev))
+		prev = start
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary decodes an index encoded by MarshalBinary. The decoded index
+// answers lookups, but can't scan any more text.
+func (ix *Index) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != indexVersion {
+		return errors.New("position: unknown index format")
+	}
+	data = data[1:]
+
+	next := func() (int, bool) {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, false
+		}
+		data = data[n:]
+		return int(v), true
+	}
+
+	var header [8]int
+	for i := range header {
+		v, ok := next()
+		if !ok {
+			return errors.New("position: truncated index")
+		}
+		header[i] = v
+	}
+
+	every, tabWidth, mode, endings := header[0], header[1], header[2], header[3]
+	length, cur, lineStart, count := header[4], header[5], header[6], header[7]
+	if every < 1 || tabWidth < 1 || mode > int(Cells) || endings > int(Unicode) || lineStart > length {
+		return errors.New("position: corrupt index")
+	}
+
+	// The start of every line before the current one that falls on the
+	// stride is recorded, see Scan.
+	if count != max(cur-1, 0)/every+1 || count > len(data) {
+		return errors.New("position: corrupt index")
+	}
+
+	starts := make([]int, count)
+	prev := 0
+	for i := range starts {
+		v, ok := next()
+		if !ok {
+			return errors.New("position: truncated index")
+		}
+		// Lines start in order, and all but the first after a line break.
+		if i == 0 && v != 0 || i > 0 && v == 0 {
+			return errors.New("position: corrupt index")
+		}
+		prev += v
+		starts[i] = prev
+	}
+
+	if prev > lineStart || len(data) > 0 {
+		return errors.New("position: corrupt index")
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.every = every
+	ix.starts = starts
+	ix.last = cur
+	ix.loaded = true
+	ix.pos = &Position{
+		lines:    []line{{bytes: length - lineStart}},
+		base:     cur,
+		origin:   lineStart,
+		offset:   length,
+		tabWidth: tabWidth,
+		mode:     ColumnMode(mode),
+		endings:  LineEndings(endings),
+	}
+
+	return nil
+}
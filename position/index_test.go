@@ -0,0 +1,266 @@
+package position_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+// scanRunes scans text into ix a few runes at a time.
+func scanRunes(t *testing.T, ix *position.Index, text string, n int) {
+	for len(text) > 0 {
+		i := 0
+		for j := 0; j < n && i < len(text); j++ {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+		}
+		require.NoError(t, ix.Scan([]byte(text[:i])))
+		text = text[i:]
+	}
+}
+
+func TestIndex(t *testing.T) {
+	text := "añ\r\n\tb🦄\r\rc\n\nde f\r\n"
+
+	for _, endings := range []position.LineEndings{position.LF, position.CRLF, position.CR, position.Unicode} {
+		for _, every := range []int{1, 2, 3} {
+			opts := []position.Option{position.WithLineEndings(endings)}
+
+			ix := position.NewIndex(every, opts...)
+			scanRunes(t, ix, text, 2)
+			ix.SetSource(strings.NewReader(text))
+
+			full := position.New(opts...)
+			full.Scan([]byte(text))
+			assert.Equal(t, full.Line(), ix.Lines())
+			assert.Equal(t, len(text), ix.Len())
+
+			for i := 0; i <= len(text); i++ {
+				if i < len(text) && !utf8.RuneStart(text[i]) {
+					continue
+				}
+
+				expected := position.New(opts...)
+				expected.Scan([]byte(text[:i]))
+
+				line, col, err := ix.LineCol(i)
+				require.NoError(t, err)
+				assert.Equal(t, expected.Line(), line, "endings %d every %d at %d", endings, every, i)
+				assert.Equal(t, expected.Column(), col, "endings %d every %d at %d", endings, every, i)
+
+				if endings == position.Unicode && i > 0 && text[i-1] == '\r' && text[i] == '\n' {
+					continue // the same line and column as right after the "\n"
+				}
+
+				offset, err := ix.Offset(line, col)
+				require.NoError(t, err)
+				assert.Equal(t, i, offset, "endings %d every %d at %d", endings, every, i)
+			}
+		}
+	}
+}
+
+func TestIndex_Graphemes(t *testing.T) {
+	text := "ééx\n👩‍👩‍👧y"
+
+	ix := position.NewIndex(1, position.WithColumnMode(position.Graphemes))
+	scanRunes(t, ix, text, 1)
+	ix.SetSource(strings.NewReader(text))
+
+	offset, err := ix.Offset(1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, len("é"), offset)
+
+	offset, err = ix.Offset(2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Index(text, "y"), offset)
+
+	line, col, err := ix.LineCol(strings.Index(text, "x"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, line)
+	assert.Equal(t, 2, col)
+}
+
+func TestIndex_Cells(t *testing.T) {
+	text := "ab界cd\n\t🦄\tx"
+
+	ix := position.NewIndex(1, position.WithColumnMode(position.Cells), position.WithTabWidth(4))
+	scanRunes(t, ix, text, 1)
+	ix.SetSource(strings.NewReader(text))
+
+	testCases := []struct {
+		line, col int
+		offset    int
+	}{
+		{1, 2, 2},
+		{1, 3, 2}, // the second cell of 界
+		{1, 4, 5},
+		{1, 6, 7},
+		{2, 0, 8},
+		{2, 1, 9}, // Column counts a tab as one column
+		{2, 2, 9}, // the second cell of 🦄
+		{2, 3, 13},
+		{2, 4, 14},
+	}
+
+	for _, tc := range testCases {
+		offset, err := ix.Offset(tc.line, tc.col)
+		require.NoError(t, err, "%d:%d", tc.line, tc.col)
+		assert.Equal(t, tc.offset, offset, "%d:%d", tc.line, tc.col)
+	}
+
+	_, err := ix.Offset(1, 7)
+	assert.Error(t, err)
+	_, err = ix.Offset(2, 6)
+	assert.Error(t, err)
+}
+
+func TestIndex_NoSource(t *testing.T) {
+	text := "ab\ncde\n\nf"
+
+	ix := position.NewIndex(1)
+	require.NoError(t, ix.Scan([]byte(text)))
+
+	line, col, err := ix.LineCol(5)
+	require.NoError(t, err)
+	assert.Equal(t, 2, line)
+	assert.Equal(t, 2, col)
+
+	offset, err := ix.Offset(4, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 9, offset)
+
+	_, err = ix.Offset(1, 3)
+	assert.Error(t, err)
+
+	sampled := position.NewIndex(2)
+	require.NoError(t, sampled.Scan([]byte(text)))
+
+	_, _, err = sampled.LineCol(5)
+	assert.ErrorIs(t, err, position.ErrNoSource)
+
+	_, err = sampled.Offset(2, 0)
+	assert.ErrorIs(t, err, position.ErrNoSource)
+}
+
+func TestIndex_OutOfRange(t *testing.T) {
+	ix := position.NewIndex(1)
+	require.NoError(t, ix.Scan([]byte("ab\ncd")))
+	ix.SetSource(strings.NewReader("ab\ncd"))
+
+	_, _, err := ix.LineCol(6)
+	assert.Error(t, err)
+
+	_, _, err = ix.LineCol(-1)
+	assert.Error(t, err)
+
+	_, err = ix.Offset(3, 0)
+	assert.Error(t, err)
+
+	_, err = ix.Offset(1, 3)
+	assert.Error(t, err)
+
+	offset, err := ix.Offset(2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 5, offset)
+}
+
+func TestIndex_Marshal(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 100; i++ {
+		sb.WriteString(strings.Repeat("x", i%7))
+		sb.WriteString("\r\n")
+	}
+	sb.WriteString("end")
+	text := sb.String()
+
+	ix := position.NewIndex(4, position.WithLineEndings(position.CRLF), position.WithTabWidth(4))
+	require.NoError(t, ix.Scan([]byte(text)))
+
+	data, err := ix.MarshalBinary()
+	require.NoError(t, err)
+
+	var loaded position.Index
+	require.NoError(t, loaded.UnmarshalBinary(data))
+	assert.Equal(t, ix.Lines(), loaded.Lines())
+	assert.Equal(t, ix.Len(), loaded.Len())
+
+	ix.SetSource(strings.NewReader(text))
+	loaded.SetSource(strings.NewReader(text))
+
+	for _, offset := range []int{0, 1, 50, 51, 52, len(text) - 3, len(text)} {
+		line, col, err := ix.LineCol(offset)
+		require.NoError(t, err)
+
+		loadedLine, loadedCol, err := loaded.LineCol(offset)
+		require.NoError(t, err)
+		assert.Equal(t, line, loadedLine)
+		assert.Equal(t, col, loadedCol)
+
+		back, err := loaded.Offset(line, col)
+		require.NoError(t, err)
+		assert.Equal(t, offset, back)
+	}
+
+	assert.ErrorIs(t, loaded.Scan([]byte("more")), position.ErrIndexLoaded)
+	assert.Equal(t, ix.Len(), loaded.Len())
+
+	assert.Error(t, loaded.UnmarshalBinary(data[:len(data)-1]))
+	assert.Error(t, loaded.UnmarshalBinary([]byte{0xff}))
+}
+
+func TestIndex_UnmarshalConsistency(t *testing.T) {
+	text := "one\r\ntwo\rthree\n\nfour five\r"
+
+	// Every encoding MarshalBinary writes is accepted back.
+	for _, every := range []int{1, 2, 3, 5} {
+		for _, endings := range []position.LineEndings{position.LF, position.CRLF, position.CR, position.Unicode} {
+			for i := 0; i <= len(text); i++ {
+				ix := position.NewIndex(every, position.WithLineEndings(endings))
+				require.NoError(t, ix.Scan([]byte(text[:i])))
+
+				data, err := ix.MarshalBinary()
+				require.NoError(t, err)
+
+				var loaded position.Index
+				require.NoError(t, loaded.UnmarshalBinary(data), "every %d endings %d at %d", every, endings, i)
+			}
+		}
+	}
+
+	// Damaged encodings are either rejected or still safe to look up.
+	ix := position.NewIndex(2, position.WithLineEndings(position.Unicode))
+	require.NoError(t, ix.Scan([]byte(text)))
+	data, err := ix.MarshalBinary()
+	require.NoError(t, err)
+
+	for i := range data {
+		for _, b := range []byte{0, 1, 2, 0x7f} {
+			damaged := append([]byte(nil), data...)
+			damaged[i] = b
+
+			var loaded position.Index
+			if loaded.UnmarshalBinary(damaged) != nil {
+				continue
+			}
+			loaded.SetSource(strings.NewReader(text))
+
+			assert.NotPanics(t, func() {
+				for offset := 0; offset <= loaded.Len(); offset++ {
+					_, _, _ = loaded.LineCol(offset)
+				}
+				for line := 1; line <= loaded.Lines(); line++ {
+					_, _ = loaded.Offset(line, 0)
+				}
+			}, "byte %d set to %d", i, b)
+		}
+	}
+
+	var loaded position.Index
+	assert.Error(t, loaded.UnmarshalBinary(data[:len(data)-1]))
+	assert.Error(t, loaded.UnmarshalBinary(append(data, 0)))
+}
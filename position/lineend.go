@@ -0,0 +1,239 @@
+package position
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// LineEndings selects which characters end a line.
+type LineEndings int
+
+const (
+	// LF only breaks lines at "\n". This is the default.
+	LF LineEndings = iota
+
+	// CRLF breaks lines at "\n" and treats "\r\n" as a single break, so the
+	// "\r" doesn't count towards the column. A lone "\r" is regular text.
+	CRLF
+
+	// CR only breaks lines at "\r", as classic Mac OS files do.
+	CR
+
+	// Unicode breaks lines at every line terminator listed by the Unicode
+	// standard: LF, CR, CRLF (as a single break), VT, FF, NEL (U+0085), LS
+	// (U+2028) and PS (U+2029).
+	Unicode
+)
+
+const (
+	carriageReturn = '\r'
+	verticalTab    = '\v'
+	formFeed       = '\f'
+	nextLine       = '\u0085'
+	lineSeparator  = '\u2028'
+	paraSeparator  = '\u2029'
+)
+
+// lineEnd is the line break that ends a line.
+type lineEnd uint8
+
+const (
+	eolNone lineEnd = iota // the line is not over yet
+	eolLF
+	eolCR
+	eolCRLF
+	eolVT
+	eolFF
+	eolNEL
+	eolLS
+	eolPS
+	eolSource // not a character, the boundary between two sources
+)
+
+// size returns the number of bytes and runes of the line break.
+func (e lineEnd) size() (int, int) {
+	switch e {
+	case eolNone, eolSource:
+		return 0, 0
+	case eolCRLF:
+		return 2, 2
+	case eolNEL:
+		return 2, 1
+	case eolLS, eolPS:
+		return 3, 1
+	}
+
+	return 1, 1
+}
+
+// WithLineEndings sets which characters end a line. The default is LF.
+func WithLineEndings(le LineEndings) Option {
+	return func(p *Position) {
+		p.endings = le
+	}
+}
+
+// LineEndings returns the line ending policy of the position.
+func (p *Position) LineEndings() LineEndings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.endings
+}
+
+// breakOf returns the kind of line break r is on its own, or eolNone if it
+// doesn't end a line. A "\n" that follows a "\r" is joined with it by Scan.
+func (le LineEndings) breakOf(r rune) lineEnd {
+	switch le {
+	case LF, CRLF:
+		if r == newLine {
+			return eolLF
+		}
+	case CR:
+		if r == carriageReturn {
+			return eolCR
+		}
+	case Unicode:
+		switch r {
+		case newLine:
+			return eolLF
+		case carriageReturn:
+			return eolCR
+		case verticalTab:
+			return eolVT
+		case formFeed:
+			return eolFF
+		case nextLine:
+			return eolNEL
+		case lineSeparator:
+			return eolLS
+		case paraSeparator:
+			return eolPS
+		}
+	}
+
+	return eolNone
+}
+
+// Index returns the index of the first byte of the first line break in b, or
+// -1 if there is none.
+func (le LineEndings) Index(b []byte) int {
+	switch le {
+	case LF:
+		return bytes.IndexByte(b, newLine)
+	case CR:
+		return bytes.IndexByte(b, carriageReturn)
+	case CRLF:
+		i := bytes.IndexByte(b, newLine)
+		if i > 0 && b[i-1] == carriageReturn {
+			return i - 1
+		}
+		return i
+	}
+
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if le.breakOf(r) != eolNone {
+			return i
+		}
+		i += size
+	}
+
+	return -1
+}
+
+// LastLineStart returns the index of the byte right after the last line break
+// in b, which is where the last line in b starts, or -1 if there is none.
+func (le LineEndings) LastLineStart(b []byte) int {
+	switch le {
+	case LF, CRLF:
+		if i := bytes.LastIndexByte(b, newLine); i >= 0 {
+			return i + 1
+		}
+		return -1
+	case CR:
+		if i := bytes.LastIndexByte(b, carriageReturn); i >= 0 {
+			return i + 1
+		}
+		return -1
+	}
+
+	for i := len(b); i > 0; {
+		r, size := utf8.DecodeLastRune(b[:i])
+		if le.breakOf(r) != eolNone {
+			return i
+		}
+		i -= size
+	}
+
+	return -1
+}
+
+// breakLine ends the current line with the given break and starts a new one.
+func (p *Position) breakLine(eol lineEnd) {
+	l := &p.lines[len(p.lines)-1]
+	l.eol = eol
+	p.srcLineStart += l.src + p.breakSource(eol)
+
+	p.lines = append(p.lines, line{})
+	p.cluster = p.cluster[:0]
+	p.clusterWidth = 0
+	p.clusterCol = 0
+}
+
+// afterCR reports whether a "\n" scanned now would complete a "\r\n" break.
+func (p *Position) afterCR() bool {
+	zl := len(p.lines) - 1
+
+	switch p.endings {
+	case CRLF:
+		// The "\r" is still part of the line, and being a control character it
+		// always leaves a stop behind.
+		n := len(p.stops)
+		if n == 0 {
+			return false
+		}
+		s := p.stops[n-1]
+		return s.line == p.base+zl && s.runes == p.lines[zl].runes && s.cluster == string(carriageReturn)
+	case Unicode:
+		return zl > 0 && p.lines[zl].bytes == 0 && p.lines[zl-1].eol == eolCR
+	}
+
+	return false
+}
+
+// joinCRLF turns the "\r" scanned last into a "\r\n" break.
+func (p *Position) joinCRLF() {
+	zl := len(p.lines) - 1
+
+	if p.endings == CRLF {
+		// Take the "\r" back from the line before breaking it.
+		p.lines[zl].runes--
+		p.lines[zl].bytes--
+		p.rewindStops()
+		p.breakLine(eolCRLF)
+		return
+	}
+
+	p.lines[zl-1].eol = eolCRLF
+	p.srcLineStart += p.breakSource(eolCRLF) - p.breakSource(eolCR)
+}
+
+// splitCRLF moves to the middle of the "\r\n" that ends line i, right after the
+// "\r". The line starts at the given source offset.
+func (p *Position) splitCRLF(i int, srcLineStart int) {
+	p.lines = p.lines[:i+1]
+	p.srcLineStart = srcLineStart
+
+	if p.endings == CRLF {
+		p.lines[i].eol = eolNone
+		p.rewindStops()
+		p.advance(carriageReturn, []byte{carriageReturn})
+		return
+	}
+
+	p.lines[i].eol = eolCR
+	p.srcLineStart += p.lines[i].src + p.breakSource(eolCR)
+	p.lines = append(p.lines, line{})
+	p.rewindStops()
+}
@@ -0,0 +1,199 @@
+package position_test
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+func TestLineEndings(t *testing.T) {
+	testCases := []struct {
+		name    string
+		endings position.LineEndings
+		text    string
+		line    int
+		column  int
+	}{
+		{"LF", position.LF, "ab\ncd", 2, 2},
+		{"LF ignores CR", position.LF, "ab\r\ncd\ref", 2, 5},
+		{"CRLF", position.CRLF, "ab\r\ncd\r\nef", 3, 2},
+		{"CRLF pending CR", position.CRLF, "ab\r\ncd\r", 2, 3},
+		{"CRLF lone LF", position.CRLF, "ab\ncd", 2, 2},
+		{"CRLF lone CR", position.CRLF, "ab\rcd", 1, 5},
+		{"CRLF empty lines", position.CRLF, "\r\n\r\n\r\n", 4, 0},
+		{"CR", position.CR, "ab\rcd\ref", 3, 2},
+		{"CR ignores LF", position.CR, "ab\ncd", 1, 5},
+		{"Unicode CRLF", position.Unicode, "ab\r\ncd", 2, 2},
+		{"Unicode CR", position.Unicode, "ab\rcd\r", 3, 0},
+		{"Unicode CR CR LF", position.Unicode, "a\r\r\nb", 3, 1},
+		{"Unicode LF CR", position.Unicode, "a\n\rb", 3, 1},
+		{"Unicode separators", position.Unicode, "a\vb\fc\u0085d\u2028e\u2029f", 6, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := position.New(position.WithLineEndings(tc.endings))
+			p.Scan([]byte(tc.text))
+			assert.Equal(t, tc.line, p.Line())
+			assert.Equal(t, tc.column, p.Column())
+			assert.Equal(t, len(tc.text), p.Offset())
+
+			// Scanning rune by rune gives the same result.
+			p = position.New(position.WithLineEndings(tc.endings))
+			for _, r := range tc.text {
+				p.Scan([]byte(string(r)))
+			}
+			assert.Equal(t, tc.line, p.Line())
+			assert.Equal(t, tc.column, p.Column())
+		})
+	}
+}
+
+func TestLineEndings_LineStart(t *testing.T) {
+	p := position.New(position.WithLineEndings(position.CRLF))
+	p.Scan([]byte("one\r\ntwo\r\nth"))
+	assert.Equal(t, 10, p.LineStart())
+
+	p = position.New(position.WithLineEndings(position.Unicode))
+	p.Scan([]byte("one\u2028two\r\nth"))
+	assert.Equal(t, 11, p.LineStart())
+}
+
+// TestLineEndings_Rewind checks that rewinding to every rune boundary, including
+// the middle of a "\r\n", gives the same position as scanning up to it.
+func TestLineEndings_Rewind(t *testing.T) {
+	texts := []string{
+		"ab\r\ncd\r\n\r\nef",
+		"\r\n\r\r\n\n\rx",
+		"日本\r\n\tx\u2028y\u0085z\r",
+	}
+
+	for _, text := range texts {
+		for _, endings := range []position.LineEndings{position.LF, position.CRLF, position.CR, position.Unicode} {
+			for _, mode := range []position.ColumnMode{position.Runes, position.Cells} {
+				opts := []position.Option{
+					position.WithLineEndings(endings),
+					position.WithColumnMode(mode),
+				}
+
+				full := position.New(opts...)
+				full.Scan([]byte(text))
+
+				for i := len(text); i >= 0; i-- {
+					if i < len(text) && !utf8.RuneStart(text[i]) {
+						continue
+					}
+
+					expected := position.New(opts...)
+					expected.Scan([]byte(text[:i]))
+
+					p := full.Copy()
+					err := p.Rewind(len(text)-i, utf8.RuneCountInString(text[i:]))
+					require.NoError(t, err)
+
+					assert.Equal(t, expected.Line(), p.Line(), "%q endings %d mode %d at %d", text, endings, mode, i)
+					assert.Equal(t, expected.Column(), p.Column(), "%q endings %d mode %d at %d", text, endings, mode, i)
+					assert.Equal(t, expected.LineStart(), p.LineStart(), "%q endings %d mode %d at %d", text, endings, mode, i)
+
+					// Scanning the rest of the text again must end up where we started.
+					p.Scan([]byte(text[i:]))
+					assert.Equal(t, full.Line(), p.Line(), "%q endings %d mode %d at %d", text, endings, mode, i)
+					assert.Equal(t, full.Column(), p.Column(), "%q endings %d mode %d at %d", text, endings, mode, i)
+				}
+			}
+		}
+	}
+}
+
+func TestLineEndings_RewindIntoSeparator(t *testing.T) {
+	p := position.New(position.WithLineEndings(position.Unicode))
+	p.Scan([]byte("a\u2028b"))
+
+	err := p.Rewind(2, 1)
+	assert.Error(t, err)
+}
+
+func TestLineEndings_Discard(t *testing.T) {
+	p := position.New(position.WithLineEndings(position.CRLF))
+	p.Scan([]byte("one\r\ntwo\r\nthree"))
+
+	p.Discard(6)
+	assert.Equal(t, 3, p.Line())
+
+	// The start of "two" is still reachable.
+	require.NoError(t, p.Rewind(10, 10))
+	assert.Equal(t, 2, p.Line())
+	assert.Equal(t, 0, p.Column())
+	assert.Equal(t, 5, p.Offset())
+	assert.Error(t, p.Rewind(1, 1))
+}
+
+func TestLineEndingsIndex(t *testing.T) {
+	testCases := []struct {
+		endings  position.LineEndings
+		text     string
+		expected int
+	}{
+		{position.LF, "ab\r\n", 3},
+		{position.LF, "ab\r", -1},
+		{position.CRLF, "ab\r\n", 2},
+		{position.CRLF, "ab\rc\n", 4},
+		{position.CRLF, "\n", 0},
+		{position.CR, "ab\nc\r\n", 4},
+		{position.Unicode, "日本\u2029", 6},
+		{position.Unicode, "ab\fc", 2},
+		{position.Unicode, "abc", -1},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.endings.Index([]byte(tc.text)), "%q endings %d", tc.text, tc.endings)
+	}
+}
+
+func TestLineEndings_DiscardPendingCR(t *testing.T) {
+	for _, mode := range []position.ColumnMode{position.Runes, position.Graphemes, position.Cells} {
+		for _, text := range []string{"x\t\r", "\t🦄\r"} {
+			opts := []position.Option{position.WithLineEndings(position.CRLF), position.WithColumnMode(mode)}
+			full := position.New(opts...)
+			full.Scan([]byte(text + "\n"))
+			require.NoError(t, full.Rewind(1, 1))
+
+			p := position.New(opts...)
+			p.Scan([]byte(text))
+			p.Discard(len(text))
+
+			// The "\n" takes the "\r" back from the line, the stop before it is
+			// still needed to work out the columns.
+			p.Scan([]byte("\n"))
+			require.NoError(t, p.Rewind(1, 1))
+			assert.Equal(t, full.Column(), p.Column(), "%q mode %d", text, mode)
+			assert.Equal(t, full.VisualColumn(), p.VisualColumn(), "%q mode %d", text, mode)
+			assert.Equal(t, full.ColumnIn(position.UTF16), p.ColumnIn(position.UTF16), "%q mode %d", text, mode)
+			assert.Equal(t, full.LastColumn(), p.LastColumn(), "%q mode %d", text, mode)
+		}
+	}
+}
+
+func TestLineEndingsLastLineStart(t *testing.T) {
+	testCases := []struct {
+		endings  position.LineEndings
+		text     string
+		expected int
+	}{
+		{position.LF, "ab\ncd\r", 3},
+		{position.LF, "ab\r", -1},
+		{position.CRLF, "ab\r\ncd", 4},
+		{position.CR, "a\rb\nc", 2},
+		{position.CR, "ab\n", -1},
+		{position.Unicode, "a\nb\u2028c", 6},
+		{position.Unicode, "ab\r\n", 4},
+		{position.Unicode, "abc", -1},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.endings.LastLineStart([]byte(tc.text)), "%q endings %d", tc.text, tc.endings)
+	}
+}
@@ -0,0 +1,72 @@
+package position
+
+// restart records the state of the source that was left by Restart.
+type restart struct {
+	line         int    // index of the first line of the new source, counting discarded lines
+	name         string // name of the previous source
+	firstLine    int    // index of the first line of the previous source
+	srcLineStart int    // source offset at which the last line of the previous source starts
+}
+
+// WithName sets the name of the source the text comes from, usually a file
+// name. It's reported by Name and String.
+func WithName(name string) Option {
+	return func(p *Position) {
+		p.name = name
+		p.startName = name
+	}
+}
+
+// Name returns the name of the source the position is in.
+func (p *Position) Name() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.name
+}
+
+// Restart starts a new source with the given name at the current offset. Line
+// and column start over from the beginning, and so does the source offset,
+// but Offset keeps counting.
+//
+// Rewinding to the exact offset a source starts at moves to the end of the
+// previous source, as if Restart hadn't been called yet.
+func (p *Position) Restart(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.lines) == 0 {
+		p.lines = append(p.lines, line{})
+	}
+
+	r := restart{
+		line:         p.base + len(p.lines),
+		name:         p.name,
+		firstLine:    p.firstLine,
+		srcLineStart: p.srcLineStart,
+	}
+	p.restarts = append(p.restarts, r)
+
+	p.breakLine(eolSource)
+	p.srcLineStart = 0
+	p.name = name
+	p.firstLine = r.line
+}
+
+// restarted reports whether the i-th line is the first line of a source other
+// than the first one.
+func (p *Position) restarted(i int) bool {
+	return i > 0 && p.lines[i-1].eol == eolSource
+}
+
+// unwindRestarts goes back to the source that was current before the n-th
+// restart, forgetting the restarts after it.
+func (p *Position) unwindRestarts(n int) {
+	if n == len(p.restarts) {
+		return
+	}
+
+	r := p.restarts[n]
+	p.name, p.firstLine = r.name, r.firstLine
+	p.restarts = p.restarts[:n]
+}
@@ -0,0 +1,80 @@
+package position_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+func TestRestart(t *testing.T) {
+	p := position.New(position.WithName("main.c"))
+	assert.Equal(t, "main.c", p.Name())
+	assert.Equal(t, "main.c:1:0", p.String())
+
+	p.Scan([]byte("int x;\n#incl"))
+	assert.Equal(t, "main.c:2:5", p.String())
+
+	p.Restart("header.h")
+	assert.Equal(t, "header.h:1:0", p.String())
+	assert.Equal(t, 12, p.Offset())
+	assert.Equal(t, 0, p.SourceOffset())
+
+	p.Scan([]byte("int y;\nint z;"))
+	assert.Equal(t, "header.h:2:6", p.String())
+	assert.Equal(t, 13, p.SourceOffset())
+	assert.Equal(t, 25, p.Offset())
+
+	// An empty source.
+	p.Restart("empty.h")
+	p.Restart("main.c")
+	p.Scan([]byte("ude"))
+	assert.Equal(t, "main.c:1:3", p.String())
+
+	// Rewind into the previous sources.
+	q := p.Copy()
+	require.NoError(t, q.Rewind(1, 1))
+	assert.Equal(t, "main.c:1:2", q.String())
+
+	q = p.Copy()
+	require.NoError(t, q.Rewind(3, 3))
+	assert.Equal(t, "header.h:2:6", q.String())
+	assert.Equal(t, 13, q.SourceOffset())
+
+	q = p.Copy()
+	require.NoError(t, q.Rewind(10, 10))
+	assert.Equal(t, "header.h:1:6", q.String())
+	assert.Equal(t, 6, q.SourceOffset())
+
+	q = p.Copy()
+	require.NoError(t, q.Rewind(16, 16))
+	assert.Equal(t, "main.c:2:5", q.String())
+	assert.Equal(t, 12, q.SourceOffset())
+
+	// Scanning again after a rewind.
+	q.Restart("other.h")
+	q.Scan([]byte("x\n"))
+	assert.Equal(t, "other.h:2:0", q.String())
+
+	require.NoError(t, q.Rewind(14, 14))
+	assert.Equal(t, "main.c:1:0", q.String())
+
+	q.Reset()
+	assert.Equal(t, "main.c", q.Name())
+}
+
+func TestRestart_Discard(t *testing.T) {
+	p := position.New(position.WithName("a"))
+	p.Scan([]byte("1\n2\n"))
+	p.Restart("b")
+	p.Scan([]byte("3\n4"))
+	assert.Equal(t, "b:2:1", p.String())
+
+	// Forget everything before the start of "b".
+	p.Discard(4)
+	assert.Equal(t, "b:2:1", p.String())
+	require.NoError(t, p.Rewind(3, 3))
+	assert.Equal(t, "b:1:0", p.String())
+	assert.Error(t, p.Rewind(1, 1))
+}
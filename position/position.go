@@ -6,49 +6,140 @@ import (
 	"unicode/utf8"
 )
 
-const newLine = '\n'
+const (
+	newLine = '\n'
+	tab     = '\t'
+
+	defaultTabWidth = 8
+)
+
+// line holds the bookkeeping for a single line of text.
+type line struct {
+	runes  int // rune count (for Rewind)
+	bytes  int // byte count (for Rewind)
+	cols   int // column in the selected mode (for Column)
+	visual int // visual column (for VisualColumn)
+	src    int // size in the original input (for SourceOffset)
+	u16    int // UTF-16 code units (for ColumnIn)
+
+	eol lineEnd // line break that ends the line, if any
+}
+
+// stop records the columns right after a rune that does not advance them by
+// exactly one, like a tab or a wide character. Between stops the columns grow
+// along with the rune count, so stops are all Rewind needs to recompute them.
+type stop struct {
+	line    int    // line number
+	runes   int    // rune count on the line, including the rune
+	bytes   int    // byte count on the line, including the rune
+	cols    int    // column after the rune
+	visual  int    // visual column after the rune
+	cluster string // grapheme cluster the rune belongs to, up to the rune
+	width   int    // width of cluster
+	start   int    // column at which cluster starts
+	src     int    // size of the line in the original input, up to the rune
+	u16     int    // UTF-16 code units on the line, including the rune
+}
 
 // Position represents a position in a text file.
+//
+// Only the lines that can still be reached by Rewind are kept in memory, see
+// Discard. Older lines are folded into a plain line counter.
 type Position struct {
 	mu sync.Mutex
 
-	runesPerLine []int // rune count per line (for Column)
-	bytesPerLine []int // byte count per line (for Rewind)
-	offset       int   // total byte offset
+	lines  []line // lines that can still be rewound into, the last one is current
+	stops  []stop // stops within lines, in scan order
+	base   int    // number of lines discarded before lines[0]
+	origin int    // byte offset at which lines[0] starts
+	floor  int    // byte offset Rewind can't move before, if past origin, see Discard
+	offset int    // total byte offset
+
+	cluster      []byte // grapheme cluster being scanned, see advance
+	clusterWidth int    // width of cluster in cells
+	clusterCol   int    // column at which cluster starts, see LastColumn
+
+	name      string    // name of the current source, see Restart
+	startName string    // name of the first source
+	firstLine int       // index of the first line of the current source, counting discarded lines
+	restarts  []restart // restarts that can still be rewound over
+
+	source       SourceEncoding
+	srcStart     int // source offset of the text, see WithSource
+	srcLineStart int // source offset at which the current line starts
+	srcSize      int // size of the original input if known, see SetSourceSize
+
+	tabWidth int
+	mode     ColumnMode
+	endings  LineEndings
+	style    *Style // style of String, DefaultStyle if nil
 }
 
-func New() *Position {
-	return &Position{
-		mu: sync.Mutex{},
+// Option configures a Position.
+type Option func(*Position)
+
+// WithTabWidth sets the distance between tab stops used by VisualColumn. The
+// default is 8.
+func WithTabWidth(n int) Option {
+	return func(p *Position) {
+		if n > 0 {
+			p.tabWidth = n
+		}
 	}
 }
 
+func New(opts ...Option) *Position {
+	p := &Position{
+		mu:       sync.Mutex{},
+		tabWidth: defaultTabWidth,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
 func (p *Position) line() int {
-	zl := len(p.runesPerLine)
+	zl := len(p.lines)
 	if zl < 1 {
-		return 1
+		return p.base + 1
 	}
 
-	return zl
+	return p.base + zl - p.firstLine
 }
 
 func (p *Position) column() int {
-	zl := len(p.runesPerLine)
+	zl := len(p.lines)
+
+	if zl == 0 {
+		return 0
+	}
+
+	return p.lines[zl-1].cols
+}
+
+func (p *Position) visualColumn() int {
+	zl := len(p.lines)
 
 	if zl == 0 {
 		return 0
 	}
 
-	return p.runesPerLine[zl-1]
+	return p.lines[zl-1].visual
 }
 
+// String returns the position as "line:col" in the style set by WithStyle,
+// prefixed by the name of the source if it has one.
 func (p *Position) String() string {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return fmt.Sprintf("%d:%d", p.line(), p.column())
+	return p.format(p.styled(), true)
 }
 
+// Line returns the line number, counting from 1.
 func (p *Position) Line() int {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -56,6 +147,9 @@ func (p *Position) Line() int {
 	return p.line()
 }
 
+// Column returns the column of the next character, counting from 0, which is
+// also the number of columns read on the line so far. What a column is depends
+// on the column mode, see WithColumnMode. See also LastColumn.
 func (p *Position) Column() int {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -63,6 +157,16 @@ func (p *Position) Column() int {
 	return p.column()
 }
 
+// VisualColumn returns the column as it would be displayed on screen, that is,
+// counting runes like Column but expanding tabs to the next tab stop (see
+// WithTabWidth).
+func (p *Position) VisualColumn() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.visualColumn()
+}
+
 func (p *Position) Offset() int {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -70,32 +174,144 @@ func (p *Position) Offset() int {
 	return p.offset
 }
 
+// LineStart returns the byte offset at which the current line starts.
+func (p *Position) LineStart() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	zl := len(p.lines)
+	if zl == 0 {
+		return p.offset
+	}
+
+	return p.offset - p.lines[zl-1].bytes
+}
+
 func (p *Position) Scan(in []byte) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	zl := len(p.runesPerLine) - 1
-	if zl < 0 {
-		p.runesPerLine = []int{0}
-		p.bytesPerLine = []int{0}
-		zl = 0
+	if len(p.lines) == 0 {
+		p.lines = append(p.lines, line{})
 	}
 
 	for len(in) > 0 {
 		r, size := utf8.DecodeRune(in)
-		if r == newLine {
-			p.runesPerLine = append(p.runesPerLine, 0)
-			p.bytesPerLine = append(p.bytesPerLine, 0)
-			zl++
-		} else {
-			p.runesPerLine[zl]++
-			p.bytesPerLine[zl] += size
+		switch eol := p.endings.breakOf(r); {
+		case eol == eolNone:
+			p.advance(r, in[:size])
+		case eol == eolLF && p.afterCR():
+			p.joinCRLF()
+		default:
+			p.breakLine(eol)
 		}
 		p.offset += size
 		in = in[size:]
 	}
 }
 
+// Discard forgets the per-line bookkeeping of every line that ends before the
+// given byte offset. Line, Column and Offset are not affected, but Rewind will
+// refuse to move before the start of the line that contains offset, or of the
+// one before it if that ends in a "\r" a "\n" could still join.
+//
+// Readers call Discard whenever data before offset becomes unreachable, which
+// keeps memory bounded by the amount of text that can still be rewound
+// instead of by the total number of lines scanned.
+func (p *Position) Discard(offset int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.lines) == 0 || offset <= p.origin {
+		return
+	}
+
+	p.discardLines(offset)
+	p.discardStops(offset)
+}
+
+// discardLines implements Discard for whole lines.
+func (p *Position) discardLines(offset int) {
+	zl := len(p.lines) - 1
+	if zl < 1 {
+		return
+	}
+
+	// Walk backwards until we find the line that contains offset.
+	i, start := zl, p.offset-p.lines[zl].bytes
+	for i > 0 && start > offset {
+		i--
+		eolBytes, _ := p.lines[i].eol.size()
+		start -= p.lines[i].bytes + eolBytes
+	}
+
+	// A "\n" right at the start of the line could still join the "\r" that
+	// ended the previous one, keep it around for that, see afterCR.
+	if i > 0 && p.lines[i-1].eol == eolCR {
+		i--
+		eolBytes, _ := p.lines[i].eol.size()
+		start -= p.lines[i].bytes + eolBytes
+	}
+
+	if i == 0 {
+		return
+	}
+
+	n := copy(p.lines, p.lines[i:])
+	p.lines = p.lines[:n]
+	p.base += i
+	p.origin = start
+
+	j := 0
+	for j < len(p.stops) && p.stops[j].line < p.base {
+		j++
+	}
+	n = copy(p.stops, p.stops[j:])
+	p.stops = p.stops[:n]
+
+	j = 0
+	for j < len(p.restarts) && p.restarts[j].line <= p.base {
+		j++
+	}
+	n = copy(p.restarts, p.restarts[j:])
+	p.restarts = p.restarts[:n]
+}
+
+// discardStops implements Discard for the stops within the lines that are
+// kept. Those before offset are only needed as a base for the columns after
+// them, so only the last one is kept (two if the last one is a pending "\r"),
+// and Rewind won't move before it.
+func (p *Position) discardStops(offset int) {
+	last, end := -1, 0
+
+	i, start := 0, p.origin
+	for j, s := range p.stops {
+		for ; i < s.line-p.base; i++ {
+			eolBytes, _ := p.lines[i].eol.size()
+			start += p.lines[i].bytes + eolBytes
+		}
+		if start+s.bytes > offset {
+			break
+		}
+		last, end = j, start+s.bytes
+	}
+
+	// A "\r" a "\n" could still join is taken back from the line by joinCRLF,
+	// keep the stop before it around as a base for the columns, see afterCR.
+	keep := last
+	if last == len(p.stops)-1 && p.afterCR() {
+		keep--
+	}
+
+	if keep < 1 {
+		return
+	}
+
+	n := copy(p.stops, p.stops[keep:])
+	p.stops = p.stops[:n]
+	p.floor = end
+}
+
 func (p *Position) Reset() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -104,9 +320,19 @@ func (p *Position) Reset() {
 }
 
 func (p *Position) reset() {
-	p.runesPerLine = p.runesPerLine[:0]
-	p.bytesPerLine = p.bytesPerLine[:0]
+	p.lines = p.lines[:0]
+	p.stops = p.stops[:0]
+	p.cluster = p.cluster[:0]
+	p.clusterWidth = 0
+	p.clusterCol = 0
+	p.base = 0
+	p.origin = 0
+	p.floor = 0
 	p.offset = 0
+	p.srcLineStart = p.srcStart
+	p.name = p.startName
+	p.firstLine = 0
+	p.restarts = p.restarts[:0]
 }
 
 func (p *Position) Copy() *Position {
@@ -116,12 +342,75 @@ func (p *Position) Copy() *Position {
 	return &Position{
 		// Note: mu is intentionally not copied - a fresh zero-value mutex is correct.
 		// Per Go docs: "A Mutex must not be copied after first use."
-		runesPerLine: append([]int(nil), p.runesPerLine...),
-		bytesPerLine: append([]int(nil), p.bytesPerLine...),
+		lines:        append([]line(nil), p.lines...),
+		stops:        append([]stop(nil), p.stops...),
+		base:         p.base,
+		origin:       p.origin,
+		floor:        p.floor,
 		offset:       p.offset,
+		cluster:      append([]byte(nil), p.cluster...),
+		clusterWidth: p.clusterWidth,
+		clusterCol:   p.clusterCol,
+		name:         p.name,
+		startName:    p.startName,
+		firstLine:    p.firstLine,
+		restarts:     append([]restart(nil), p.restarts...),
+		source:       p.source,
+		srcStart:     p.srcStart,
+		srcLineStart: p.srcLineStart,
+		srcSize:      p.srcSize,
+		tabWidth:     p.tabWidth,
+		mode:         p.mode,
+		endings:      p.endings,
+		style:        p.style,
 	}
 }
 
+// Checkpoint returns a copy of the position as it was at the start of the
+// current line. Unlike Copy, it keeps no history: it can't be rewound, but it
+// takes little memory and scanning the text of the line from it gives the same
+// results as scanning it from the original position.
+func (p *Position) Checkpoint() *Position {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c := &Position{
+		base:         p.base,
+		offset:       p.offset,
+		name:         p.name,
+		startName:    p.startName,
+		firstLine:    p.firstLine,
+		source:       p.source,
+		srcStart:     p.srcStart,
+		srcLineStart: p.srcLineStart,
+		srcSize:      p.srcSize,
+		tabWidth:     p.tabWidth,
+		mode:         p.mode,
+		endings:      p.endings,
+		style:        p.style,
+	}
+
+	zl := len(p.lines) - 1
+	if zl < 0 {
+		return c
+	}
+
+	c.offset -= p.lines[zl].bytes
+	c.origin = c.offset
+
+	// A "\n" right at the start of the line could still join the "\r" that
+	// ended the previous one, keep it around for that.
+	if zl > 0 && p.lines[zl-1].eol == eolCR {
+		c.lines = append(c.lines, p.lines[zl-1])
+		zl--
+	}
+
+	c.lines = append(c.lines, line{})
+	c.base += zl
+
+	return c
+}
+
 func (p *Position) Rewind(bytes, runes int) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -131,8 +420,8 @@ func (p *Position) Rewind(bytes, runes int) error {
 		return nil // no-op
 	case bytes < 0 || runes < 0:
 		return fmt.Errorf("cannot rewind by negative amounts: bytes=%d, runes=%d", bytes, runes)
-	case bytes > p.offset:
-		return fmt.Errorf("cannot rewind by %d bytes, only %d available", bytes, p.offset)
+	case bytes > p.offset-max(p.origin, p.floor):
+		return fmt.Errorf("cannot rewind by %d bytes, only %d available", bytes, p.offset-max(p.origin, p.floor))
 	case bytes == p.offset:
 		p.reset()
 		return nil
@@ -140,14 +429,18 @@ func (p *Position) Rewind(bytes, runes int) error {
 
 	bytesRewound := 0
 	runesRewound := 0
-	lastLine := len(p.bytesPerLine) - 1
+	lastLine := len(p.lines) - 1
+	srcLineStart := p.srcLineStart
+	restarts := len(p.restarts)
 
-	for bytesRewound < bytes && lastLine >= 0 {
-		lineBytes := p.bytesPerLine[lastLine]
-		lineRunes := p.runesPerLine[lastLine]
+	for lastLine >= 0 {
+		lineBytes := p.lines[lastLine].bytes
+		lineRunes := p.lines[lastLine].runes
 		remainingBytes := bytes - bytesRewound
 
-		if remainingBytes <= lineBytes {
+		// Landing right at the start of a source means being at the end of the
+		// previous one, see Restart.
+		if remainingBytes < lineBytes || remainingBytes == lineBytes && !p.restarted(lastLine) {
 			// Partial rewind within this line
 			break
 		}
@@ -158,8 +451,29 @@ func (p *Position) Rewind(bytes, runes int) error {
 		lastLine--
 
 		if lastLine >= 0 {
-			bytesRewound++ // for the newline
-			runesRewound++ // newline is 1 rune
+			eol := p.lines[lastLine].eol
+			eolBytes, eolRunes := eol.size()
+
+			if remaining := bytes - bytesRewound; remaining < eolBytes {
+				if eol != eolCRLF {
+					return fmt.Errorf("cannot rewind into the middle of a line break")
+				}
+
+				// Landing between "\r" and "\n".
+				p.splitCRLF(lastLine, srcLineStart-p.breakSource(eol)-p.lines[lastLine].src)
+				p.offset -= bytes
+				return nil
+			}
+
+			bytesRewound += eolBytes
+			runesRewound += eolRunes
+
+			if eol == eolSource {
+				restarts--
+				srcLineStart = p.restarts[restarts].srcLineStart
+			} else {
+				srcLineStart -= p.lines[lastLine].src + p.breakSource(eol)
+			}
 		}
 	}
 
@@ -167,15 +481,61 @@ func (p *Position) Rewind(bytes, runes int) error {
 		remainingBytes := bytes - bytesRewound
 		remainingRunes := runes - runesRewound
 
-		if p.bytesPerLine[lastLine] >= remainingBytes {
-			p.bytesPerLine[lastLine] -= remainingBytes
-			p.runesPerLine[lastLine] -= remainingRunes
-			p.bytesPerLine = p.bytesPerLine[:lastLine+1]
-			p.runesPerLine = p.runesPerLine[:lastLine+1]
+		if p.lines[lastLine].bytes >= remainingBytes {
+			p.lines[lastLine].bytes -= remainingBytes
+			p.lines[lastLine].runes -= remainingRunes
+			p.lines[lastLine].eol = eolNone
+			p.lines = p.lines[:lastLine+1]
 			p.offset -= bytes
+			p.srcLineStart = srcLineStart
+			p.unwindRestarts(restarts)
+			p.rewindStops()
 			return nil
 		}
 	}
 
 	return fmt.Errorf("rewind failed: wanted %d bytes, rewound %d", bytes, bytesRewound)
 }
+
+// rewindStops drops the stops past the current position and recomputes the
+// columns of the current line from the last stop that remains.
+func (p *Position) rewindStops() {
+	zl := len(p.lines) - 1
+	l := &p.lines[zl]
+	cur := p.base + zl
+
+	n := len(p.stops)
+	for n > 0 && (p.stops[n-1].line > cur || p.stops[n-1].line == cur && p.stops[n-1].runes > l.runes) {
+		n--
+	}
+	p.stops = p.stops[:n]
+
+	// Runes between stops are regular ones, see advance.
+	l.cols, l.visual, l.src, l.u16 = l.runes, l.runes, l.runes*p.source.unit(), l.runes
+	p.cluster, p.clusterWidth, p.clusterCol = p.cluster[:0], 0, 0
+	if l.runes > 0 {
+		p.cluster, p.clusterWidth = append(p.cluster, regularRune), 1
+	}
+
+	exact := false
+	if n > 0 && p.stops[n-1].line == cur {
+		s := p.stops[n-1]
+		l.cols = s.cols + l.runes - s.runes
+		l.visual = s.visual + l.runes - s.runes
+		l.src = s.src + (l.runes-s.runes)*p.source.unit()
+		l.u16 = s.u16 + l.runes - s.runes
+
+		if s.runes == l.runes {
+			p.cluster, p.clusterWidth, p.clusterCol = append(p.cluster[:0], s.cluster...), s.width, s.start
+			exact = true
+		}
+	}
+
+	if !exact && l.runes > 0 {
+		p.clusterCol = l.cols - 1
+	}
+
+	if p.source == SourceUTF8 {
+		l.src = l.bytes
+	}
+}
@@ -1,8 +1,11 @@
 package position_test
 
 import (
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -285,3 +288,67 @@ func TestCopySafety(t *testing.T) {
 		wg.Wait()
 	})
 }
+
+// TestCheckpoint checks that scanning the rest of a text from a checkpoint
+// gives the same position as scanning all of it.
+func TestCheckpoint(t *testing.T) {
+	text := "ab\r\ncd\r\ré\tf\n"
+
+	for _, endings := range []position.LineEndings{position.LF, position.CRLF, position.Unicode} {
+		for i := 0; i <= len(text); i++ {
+			if i < len(text) && !utf8.RuneStart(text[i]) {
+				continue
+			}
+
+			opts := []position.Option{position.WithLineEndings(endings), position.WithSource(position.SourceUTF16, 2)}
+
+			p := position.New(opts...)
+			p.Scan([]byte(text[:i]))
+
+			cp := p.Checkpoint()
+			assert.Equal(t, p.LineStart(), cp.Offset())
+			assert.Equal(t, p.Line(), cp.Line())
+			assert.Equal(t, 0, cp.Column())
+
+			cp.Scan([]byte(text[cp.Offset():]))
+			p.Scan([]byte(text[i:]))
+			assert.Equal(t, p.String(), cp.String(), "endings %d at %d", endings, i)
+			assert.Equal(t, p.Offset(), cp.Offset())
+			assert.Equal(t, p.SourceOffset(), cp.SourceOffset(), "endings %d at %d", endings, i)
+		}
+	}
+}
+
+func TestDiscard_LongLine(t *testing.T) {
+	for _, chunk := range []string{strings.Repeat("\t", 64), strings.Repeat("🦄", 16)} {
+		p := position.New()
+
+		// Scan a single long line, discarding all but the last chunk like a
+		// reader with a small buffer does.
+		for i := 0; i < 1<<17/utf8.RuneCountInString(chunk); i++ {
+			p.Scan([]byte(chunk))
+			p.Discard(p.Offset() - len(chunk))
+		}
+		require.Equal(t, 1, p.Line())
+		require.Equal(t, 1<<17, p.Column())
+
+		// Copies don't carry the stops of the whole line around.
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		for i := 0; i < 10; i++ {
+			_ = p.Copy()
+		}
+		runtime.ReadMemStats(&after)
+		assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(64*1024))
+
+		// The last chunk can still be rewound, but not what came before it.
+		visual := p.VisualColumn()
+		require.NoError(t, p.Rewind(len(chunk), utf8.RuneCountInString(chunk)))
+		assert.Equal(t, 1<<17-utf8.RuneCountInString(chunk), p.Column())
+		assert.Less(t, p.VisualColumn(), visual)
+		assert.Error(t, p.Rewind(1, 1))
+
+		p.Scan([]byte(chunk))
+		assert.Equal(t, visual, p.VisualColumn())
+	}
+}
@@ -0,0 +1,91 @@
+package position
+
+// SourceEncoding is the encoding the scanned text had in its original input,
+// before it was decoded into UTF-8.
+type SourceEncoding int
+
+const (
+	// SourceUTF8 means the text was not decoded. This is the default.
+	SourceUTF8 SourceEncoding = iota
+
+	// SourceUTF16 means the text was decoded from UTF-16: every rune took two
+	// bytes, or four if it needed a surrogate pair.
+	SourceUTF16
+
+	// SourceLatin1 means the text was decoded from ISO-8859-1: every rune took
+	// one byte.
+	SourceLatin1
+)
+
+// WithSource tells the position that the text it scans was decoded from the
+// given encoding, and that it starts at the given byte offset of the original
+// input, right after a byte order mark for instance. SourceOffset uses it to
+// point into the original input.
+func WithSource(enc SourceEncoding, offset int) Option {
+	return func(p *Position) {
+		p.source = enc
+		p.srcStart = offset
+		p.srcLineStart = offset
+	}
+}
+
+// SetSourceSize tells the position the size of the original input, once it's
+// known, so that SourceOffset never points past its end. A lone byte at the
+// end of UTF-16 input, for instance, is decoded into a replacement character
+// that would otherwise count as two bytes.
+func (p *Position) SetSourceSize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.srcSize = n
+}
+
+// SourceOffset returns the byte offset in the original input, see WithSource.
+// It is the same as Offset unless the text was decoded from another encoding.
+func (p *Position) SourceOffset() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	offset := p.srcLineStart
+	if zl := len(p.lines); zl > 0 {
+		offset += p.lines[zl-1].src
+	}
+
+	if p.srcSize > 0 {
+		offset = min(offset, p.srcSize)
+	}
+
+	return offset
+}
+
+// unit returns the size in the original input of a rune that doesn't need a
+// stop, that is, a rune in the Basic Multilingual Plane.
+func (e SourceEncoding) unit() int {
+	if e == SourceUTF16 {
+		return 2
+	}
+	return 1
+}
+
+// sourceSize returns the size in the original input of the rune r, which took
+// n bytes in UTF-8.
+func (p *Position) sourceSize(r rune, n int) int {
+	switch p.source {
+	case SourceUTF16:
+		return 2 * utf16Len(r)
+	case SourceLatin1:
+		return 1
+	}
+
+	return n
+}
+
+// breakSource returns the size of a line break in the original input.
+func (p *Position) breakSource(eol lineEnd) int {
+	bytes, runes := eol.size()
+	if p.source == SourceUTF8 {
+		return bytes
+	}
+
+	return runes * p.source.unit()
+}
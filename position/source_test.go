@@ -0,0 +1,93 @@
+package position_test
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+func TestSourceOffset(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   position.SourceEncoding
+		start    int
+		text     string
+		expected int
+	}{
+		{"UTF-8", position.SourceUTF8, 0, "añ🦄\n", 8},
+		{"UTF-8 with BOM", position.SourceUTF8, 3, "añ🦄\n", 11},
+		{"UTF-16", position.SourceUTF16, 0, "añ日\n", 8},
+		{"UTF-16 surrogates", position.SourceUTF16, 2, "a🦄b\n🦄", 16},
+		{"Latin-1", position.SourceLatin1, 0, "añ\nÿ", 4},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := position.New(position.WithSource(tc.source, tc.start))
+			assert.Equal(t, tc.start, p.SourceOffset())
+
+			p.Scan([]byte(tc.text))
+			assert.Equal(t, tc.expected, p.SourceOffset())
+			assert.Equal(t, len(tc.text), p.Offset())
+
+			p.Reset()
+			assert.Equal(t, tc.start, p.SourceOffset())
+		})
+	}
+}
+
+func TestSetSourceSize(t *testing.T) {
+	// "a" and a lone byte at the end of UTF-16 input, decoded as U+FFFD.
+	p := position.New(position.WithSource(position.SourceUTF16, 2))
+	p.SetSourceSize(5)
+
+	p.Scan([]byte("a"))
+	assert.Equal(t, 4, p.SourceOffset())
+	p.Scan([]byte("\uFFFD"))
+	assert.Equal(t, 5, p.SourceOffset())
+	assert.Equal(t, 5, p.Copy().SourceOffset())
+
+	require.NoError(t, p.Rewind(3, 1))
+	assert.Equal(t, 4, p.SourceOffset())
+}
+
+// TestSourceOffset_Rewind checks that rewinding to every rune boundary gives the
+// same source offset as scanning up to it.
+func TestSourceOffset_Rewind(t *testing.T) {
+	text := "a🦄\r\nñ\tb🦄🦄\r\rc 日"
+
+	for _, source := range []position.SourceEncoding{position.SourceUTF8, position.SourceUTF16, position.SourceLatin1} {
+		for _, endings := range []position.LineEndings{position.LF, position.CRLF, position.Unicode} {
+			for _, mode := range []position.ColumnMode{position.Runes, position.Graphemes} {
+				opts := []position.Option{
+					position.WithSource(source, 2),
+					position.WithLineEndings(endings),
+					position.WithColumnMode(mode),
+				}
+
+				full := position.New(opts...)
+				full.Scan([]byte(text))
+
+				for i := len(text); i >= 0; i-- {
+					if i < len(text) && !utf8.RuneStart(text[i]) {
+						continue
+					}
+
+					expected := position.New(opts...)
+					expected.Scan([]byte(text[:i]))
+
+					p := full.Copy()
+					err := p.Rewind(len(text)-i, utf8.RuneCountInString(text[i:]))
+					require.NoError(t, err)
+					assert.Equal(t, expected.SourceOffset(), p.SourceOffset(), "source %d endings %d mode %d at %d", source, endings, mode, i)
+
+					p.Scan([]byte(text[i:]))
+					assert.Equal(t, full.SourceOffset(), p.SourceOffset(), "source %d endings %d mode %d at %d", source, endings, mode, i)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package position
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Span is a range of text, from Start up to, but not including, End.
+type Span struct {
+	Start *Position `json:"start"`
+	End   *Position `json:"end"`
+}
+
+// Contains reports whether p is within the span.
+func (s Span) Contains(p *Position) bool {
+	offset := p.Offset()
+	return s.Start.Offset() <= offset && offset < s.End.Offset()
+}
+
+// ContainsSpan reports whether all of o is within the span.
+func (s Span) ContainsSpan(o Span) bool {
+	return s.Start.Offset() <= o.Start.Offset() && o.End.Offset() <= s.End.Offset()
+}
+
+// Union returns the smallest span that contains both s and o.
+func (s Span) Union(o Span) Span {
+	u := s
+	if o.Start.Offset() < u.Start.Offset() {
+		u.Start = o.Start
+	}
+	if o.End.Offset() > u.End.Offset() {
+		u.End = o.End
+	}
+	return u
+}
+
+// String returns the span in "line:col-line:col" form, in the style of Start,
+// see Format.
+func (s Span) String() string {
+	s.Start.mu.Lock()
+	style := s.Start.styled()
+	s.Start.mu.Unlock()
+
+	return s.Format(style)
+}
+
+// Format returns the span in "line:col-line:col" form in the given style,
+// prefixed by the name of the source if it has one. The name of the end is
+// only given if it's not the same source.
+func (s Span) Format(style Style) string {
+	start, name := s.Start.Format(style), s.Start.Name()
+
+	s.End.mu.Lock()
+	defer s.End.mu.Unlock()
+
+	return start + "-" + s.End.format(style, s.End.name != name)
+}
+
+// jsonPosition is the JSON form of a Position.
+type jsonPosition struct {
+	Name   string `json:"name,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+// MarshalJSON encodes the name, line, column and offset of the position.
+func (p *Position) MarshalJSON() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return json.Marshal(jsonPosition{
+		Name:   p.name,
+		Line:   p.line(),
+		Column: p.column(),
+		Offset: p.offset,
+	})
+}
+
+// UnmarshalJSON decodes a position encoded by MarshalJSON. Only the name, line,
+// column and offset are restored, so the position can't be rewound and the
+// other columns, like ColumnIn, read like Column does.
+func (p *Position) UnmarshalJSON(data []byte) error {
+	var jp jsonPosition
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+
+	if jp.Line < 1 || jp.Column < 0 || jp.Offset < 0 {
+		return fmt.Errorf("invalid position %d:%d at offset %d", jp.Line, jp.Column, jp.Offset)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reset()
+	p.lines = append(p.lines, line{runes: jp.Column, bytes: jp.Column, cols: jp.Column, visual: jp.Column, u16: jp.Column})
+	p.base = jp.Line - 1
+	p.offset = jp.Offset
+	p.origin = jp.Offset
+	p.name, p.startName = jp.Name, jp.Name
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package position_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+// at returns the position after scanning text.
+func at(text string, opts ...position.Option) *position.Position {
+	p := position.New(opts...)
+	p.Scan([]byte(text))
+	return p
+}
+
+func TestSpan(t *testing.T) {
+	text := "func main() {\n\treturn\n}"
+
+	body := position.Span{Start: at(text[:12]), End: at(text)}
+	ret := position.Span{Start: at(text[:15]), End: at(text[:21])}
+	name := position.Span{Start: at(text[:5]), End: at(text[:9])}
+
+	assert.Equal(t, "1:12-3:1", body.String())
+	assert.Equal(t, "2:1-2:7", ret.String())
+
+	assert.True(t, body.ContainsSpan(ret))
+	assert.False(t, ret.ContainsSpan(body))
+	assert.False(t, body.ContainsSpan(name))
+	assert.True(t, body.ContainsSpan(body))
+
+	assert.True(t, ret.Contains(at(text[:15])))
+	assert.True(t, ret.Contains(at(text[:20])))
+	assert.False(t, ret.Contains(at(text[:21])))
+	assert.False(t, ret.Contains(at(text[:14])))
+
+	u := name.Union(ret)
+	assert.Equal(t, "1:5-2:7", u.String())
+	assert.Equal(t, u, ret.Union(name))
+	assert.True(t, u.ContainsSpan(name))
+	assert.True(t, u.ContainsSpan(ret))
+}
+
+func TestSpan_String(t *testing.T) {
+	start := at("ab\ncd", position.WithName("a.txt"))
+	end := at("ab\ncdef", position.WithName("a.txt"))
+	assert.Equal(t, "a.txt:2:2-2:4", position.Span{Start: start, End: end}.String())
+
+	other := at("x", position.WithName("b.txt"))
+	assert.Equal(t, "a.txt:2:2-b.txt:1:1", position.Span{Start: start, End: other}.String())
+}
+
+func TestSpan_JSON(t *testing.T) {
+	s := position.Span{
+		Start: at("ab\n\tc", position.WithName("a.txt")),
+		End:   at("ab\n\tcdé", position.WithName("a.txt")),
+	}
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"start": {"name": "a.txt", "line": 2, "column": 2, "offset": 5},
+		"end": {"name": "a.txt", "line": 2, "column": 4, "offset": 8}
+	}`, string(data))
+
+	var decoded position.Span
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, s.String(), decoded.String())
+	assert.Equal(t, 5, decoded.Start.Offset())
+	assert.Equal(t, 8, decoded.End.Offset())
+
+	// Decoded positions can still be scanned from.
+	decoded.End.Scan([]byte("f\ng"))
+	assert.Equal(t, "a.txt:3:1", decoded.End.String())
+	assert.Equal(t, 11, decoded.End.Offset())
+
+	unnamed, err := json.Marshal(at("x"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"line": 1, "column": 1, "offset": 1}`, string(unnamed))
+
+	var p position.Position
+	assert.Error(t, json.Unmarshal([]byte(`{"line": 0, "column": 0, "offset": 0}`), &p))
+}
@@ -0,0 +1,81 @@
+package position
+
+import "fmt"
+
+// Style selects the numbers String and Format give to the first line and the
+// first column. Whatever the style, Line and Column keep counting lines from 1
+// and columns from 0.
+type Style struct {
+	LineBase int // number of the first line
+	ColBase  int // number of the first column
+}
+
+var (
+	// DefaultStyle numbers lines from 1 and columns from 0, so the column is
+	// the number of columns before the position. It's the style of String
+	// unless WithStyle says otherwise.
+	DefaultStyle = Style{LineBase: 1, ColBase: 0}
+
+	// EditorStyle numbers lines and columns from 1, like most editors and
+	// compilers do.
+	EditorStyle = Style{LineBase: 1, ColBase: 1}
+
+	// LSPStyle numbers lines and columns from 0, like the Language Server
+	// Protocol does.
+	LSPStyle = Style{LineBase: 0, ColBase: 0}
+)
+
+// WithStyle sets the style String uses. The default is DefaultStyle.
+func WithStyle(s Style) Option {
+	return func(p *Position) {
+		p.style = &s
+	}
+}
+
+// Format returns the position as "line:col" in the given style, prefixed by
+// the name of the source if it has one. The column is the one of the next
+// rune, see Column.
+func (p *Position) Format(s Style) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.format(s, true)
+}
+
+// LastColumn returns the column at which the last character read on the line
+// starts, counting from 0 like Column, or -1 if nothing was read on the line
+// yet. Column is the column of the next character instead: after reading "ab"
+// LastColumn is 1 and Column is 2. In Cells mode a zero-width character, like
+// a control character, starts at the same column as the next one.
+func (p *Position) LastColumn() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	zl := len(p.lines)
+	if zl == 0 || p.lines[zl-1].runes == 0 {
+		return -1
+	}
+
+	if p.mode == Cells {
+		return p.clusterCol
+	}
+
+	return p.lines[zl-1].cols - 1
+}
+
+// styled returns the style String uses.
+func (p *Position) styled() Style {
+	if p.style == nil {
+		return DefaultStyle
+	}
+	return *p.style
+}
+
+// format implements Format, leaving the name out if withName is false.
+func (p *Position) format(s Style, withName bool) string {
+	lc := fmt.Sprintf("%d:%d", p.line()-1+s.LineBase, p.column()+s.ColBase)
+	if withName && p.name != "" {
+		return p.name + ":" + lc
+	}
+	return lc
+}
@@ -0,0 +1,81 @@
+package position_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+func TestFormat(t *testing.T) {
+	p := at("ab\ncd", position.WithName("a.txt"))
+
+	assert.Equal(t, "a.txt:2:2", p.String())
+	assert.Equal(t, "a.txt:2:2", p.Format(position.DefaultStyle))
+	assert.Equal(t, "a.txt:2:3", p.Format(position.EditorStyle))
+	assert.Equal(t, "a.txt:1:2", p.Format(position.LSPStyle))
+	assert.Equal(t, "a.txt:11:102", p.Format(position.Style{LineBase: 10, ColBase: 100}))
+
+	// The accessors don't change with the style.
+	assert.Equal(t, 2, p.Line())
+	assert.Equal(t, 2, p.Column())
+}
+
+func TestWithStyle(t *testing.T) {
+	p := at("ab\ncd", position.WithStyle(position.EditorStyle))
+	assert.Equal(t, "2:3", p.String())
+	assert.Equal(t, "2:3", p.Copy().String())
+	assert.Equal(t, "2:1", p.Checkpoint().String())
+
+	p.Reset()
+	assert.Equal(t, "1:1", p.String())
+
+	s := position.Span{Start: at("a", position.WithStyle(position.LSPStyle)), End: at("ab\nc")}
+	assert.Equal(t, "0:1-1:1", s.String())
+	assert.Equal(t, "1:2-2:2", s.Format(position.EditorStyle))
+}
+
+func TestLastColumn(t *testing.T) {
+	testCases := []struct {
+		name   string
+		text   string
+		mode   position.ColumnMode
+		last   int
+		column int
+	}{
+		{"start of input", "", position.Runes, -1, 0},
+		{"start of line", "ab\n", position.Runes, -1, 0},
+		{"runes", "ab", position.Runes, 1, 2},
+		{"tab", "a\t", position.Runes, 1, 2},
+		{"graphemes", "aé", position.Graphemes, 1, 2},
+		{"wide", "a日", position.Cells, 1, 3},
+		{"wide grapheme", "a🦄", position.Cells, 1, 3},
+		{"cells tab", "a\t", position.Cells, 1, 2},
+		{"cells after tab", "a\tb", position.Cells, 2, 3},
+		{"cells combining", "ae\u0301", position.Cells, 1, 2},
+		// Zero-width characters start where the next one does.
+		{"cells control", "a\x01", position.Cells, 1, 1},
+		{"cells wide control", "日\x01", position.Cells, 2, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := at(tc.text, position.WithColumnMode(tc.mode))
+			assert.Equal(t, tc.last, p.LastColumn())
+			assert.Equal(t, tc.column, p.Column())
+		})
+	}
+
+	t.Run("rewind", func(t *testing.T) {
+		p := at("a\tb日", position.WithColumnMode(position.Cells))
+		assert.Equal(t, 3, p.LastColumn())
+
+		require.NoError(t, p.Rewind(len("b日"), 2))
+		assert.Equal(t, 1, p.LastColumn())
+		assert.Equal(t, 2, p.Column())
+
+		require.NoError(t, p.Rewind(1, 1))
+		assert.Equal(t, 0, p.LastColumn())
+	})
+}
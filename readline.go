@@ -0,0 +1,144 @@
+package textreader
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"unicode/utf8"
+)
+
+// ReadSlice reads until the first occurrence of delim in the input, returning
+// a slice pointing at the bytes in the buffer, like bufio.Reader.ReadSlice
+// does. The bytes stop being valid at the next read. If the buffer fills
+// without a delim, ReadSlice fails with bufio.ErrBufferFull, returning the
+// buffer up to the last complete rune. Unlike a bufio.Reader wrapping the
+// TextReader, it moves the position only over the bytes it returns.
+//
+// In StrictUTF8 mode, ReadSlice stops at invalid input and returns the data
+// before it along with an *InvalidUTF8Error.
+func (t *TextReader) ReadSlice(delim byte) (line []byte, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("read slice", err) }()
+
+	return t.readSlice(delim)
+}
+
+// ReadLine reads a line, not including the "\n" or "\r\n" at its end, like
+// bufio.Reader.ReadLine does: if the line doesn't fit in the buffer, isPrefix
+// is set and the rest of the line is returned by the following calls. Lines
+// always end at "\n" here, whatever the line endings of the position are. The
+// returned bytes stop being valid at the next read.
+//
+// Most callers should use ReadString('\n') or ReadBytes('\n') instead.
+func (t *TextReader) ReadLine() (line []byte, isPrefix bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("read line", err) }()
+
+	line, err = t.readSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		// Leave a "\r" at the end for the next call, it might be followed by
+		// a "\n".
+		if n := len(line); n > 1 && line[n-1] == '\r' {
+			if err := t.pos.Rewind(1, 1); err != nil {
+				return nil, false, err
+			}
+			t.r--
+			line = line[:n-1]
+		}
+		return line, true, nil
+	}
+
+	if len(line) == 0 {
+		if err != nil {
+			line = nil
+		}
+		return line, false, err
+	}
+
+	if line[len(line)-1] == '\n' {
+		drop := 1
+		if len(line) > 1 && line[len(line)-2] == '\r' {
+			drop = 2
+		}
+		line = line[:len(line)-drop]
+	}
+
+	// Any error comes up again at the next call, once the line is done with.
+	return line, false, nil
+}
+
+// ReadBytes reads until the first occurrence of delim in the input, returning
+// a copy of the bytes up to and including the delim, like
+// bufio.Reader.ReadBytes does. Lines longer than the buffer are fine. It
+// returns an error if and only if the bytes don't end in delim.
+func (t *TextReader) ReadBytes(delim byte) (line []byte, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("read bytes", err) }()
+
+	return t.readBytes(delim)
+}
+
+// ReadString is like ReadBytes, but returns a string.
+func (t *TextReader) ReadString(delim byte) (line string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("read string", err) }()
+
+	b, err := t.readBytes(delim)
+	return string(b), err
+}
+
+// readSlice implements ReadSlice.
+func (t *TextReader) readSlice(delim byte) ([]byte, error) {
+	defer t.history.clear()
+
+	var err error
+
+	n, searched := 0, 0
+	for {
+		if i := bytes.IndexByte(t.buf[t.r+searched:t.w], delim); i >= 0 {
+			n, err = searched+i+1, nil
+			break
+		}
+
+		searched = t.w - t.r
+		if err != nil {
+			n = searched
+			break
+		}
+
+		if searched >= t.maxCapacity {
+			// Don't cut a rune in half, the position can't take it.
+			n, err = searched, bufio.ErrBufferFull
+			if i := lastRuneStart(t.buf[t.r:t.w]); i > 0 && !utf8.FullRune(t.buf[t.r+i:t.w]) {
+				n = i
+			}
+			break
+		}
+
+		_, err = t.fillAtLeast(searched + 1)
+	}
+
+	start := t.r
+	if _, cerr := t.consume(n); cerr != nil {
+		return t.buf[start:t.r], cerr
+	}
+
+	return t.buf[start:t.r], err
+}
+
+// readBytes implements ReadBytes.
+func (t *TextReader) readBytes(delim byte) ([]byte, error) {
+	var line []byte
+	for {
+		b, err := t.readSlice(delim)
+		line = append(line, b...)
+
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, err
+		}
+	}
+}
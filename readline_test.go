@@ -0,0 +1,170 @@
+package textreader
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+const readLineText = "héllo wörld\r\n\tshort\n\nthis 🦄 line is longer than the buffer\r\nend"
+
+func TestReadString(t *testing.T) {
+	for _, capacity := range []int{8, 16, 0} {
+		tr := newReader(readLineText, capacity)
+
+		expected := position.New()
+		for _, want := range strings.SplitAfter(readLineText, "\n") {
+			line, err := tr.ReadString('\n')
+			if strings.HasSuffix(want, "\n") {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, io.EOF)
+			}
+			assert.Equal(t, want, line)
+
+			expected.Scan([]byte(want))
+			assert.Equal(t, expected.String(), tr.Pos().String())
+			assert.Equal(t, expected.Offset(), tr.Pos().Offset())
+		}
+
+		line, err := tr.ReadString('\n')
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, "", line)
+	}
+}
+
+func TestReadBytes(t *testing.T) {
+	tr := New(iotest.OneByteReader(strings.NewReader("a,b🦄,,c")))
+
+	var fields []string
+	for {
+		b, err := tr.ReadBytes(',')
+		fields = append(fields, string(b))
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"a,", "b🦄,", ",", "c"}, fields)
+	assert.Equal(t, "1:7", tr.Pos().String())
+}
+
+func TestReadLine(t *testing.T) {
+	for _, capacity := range []int{8, 14, 0} {
+		tr := newReader(readLineText, capacity)
+
+		var lines []string
+		var sb strings.Builder
+		for {
+			line, isPrefix, err := tr.ReadLine()
+			if err != nil {
+				assert.Equal(t, io.EOF, err)
+				assert.Nil(t, line)
+				break
+			}
+
+			sb.Write(line)
+			if isPrefix {
+				continue
+			}
+
+			lines = append(lines, sb.String())
+			sb.Reset()
+
+			// Every line but the last one ends with a line break.
+			if len(lines) < 5 {
+				assert.Equal(t, len(lines)+1, tr.Pos().Line())
+				assert.Equal(t, 0, tr.Pos().Column())
+			}
+		}
+
+		assert.Equal(t, []string{"héllo wörld", "\tshort", "", "this 🦄 line is longer than the buffer", "end"}, lines, "capacity %d", capacity)
+		assert.Equal(t, len(readLineText), tr.Pos().Offset())
+	}
+}
+
+func TestReadLine_SplitCRLF(t *testing.T) {
+	// The buffer fills up right after the "\r".
+	tr := newReader("abcdefg\r\nh", 8)
+
+	line, isPrefix, err := tr.ReadLine()
+	require.NoError(t, err)
+	assert.True(t, isPrefix)
+	assert.Equal(t, "abcdefg", string(line))
+	assert.Equal(t, 7, tr.Pos().Offset())
+
+	line, isPrefix, err = tr.ReadLine()
+	require.NoError(t, err)
+	assert.False(t, isPrefix)
+	assert.Equal(t, "", string(line))
+	assert.Equal(t, "2:0", tr.Pos().String())
+
+	line, _, err = tr.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "h", string(line))
+}
+
+func TestReadSlice(t *testing.T) {
+	tr := newReader("abcdefg日本\nx", 8)
+
+	b, err := tr.ReadSlice('\n')
+	assert.ErrorIs(t, err, bufio.ErrBufferFull)
+	assert.Equal(t, "abcdefg", string(b))
+	assert.Equal(t, 7, tr.Pos().Column())
+
+	b, err = tr.ReadSlice('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "日本\n", string(b))
+	assert.Equal(t, "2:0", tr.Pos().String())
+
+	r, _, err := tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, 'x', r)
+}
+
+func TestReadString_InvalidUTF8(t *testing.T) {
+	t.Run("strict", func(t *testing.T) {
+		tr := New(strings.NewReader("ab\xffcd\nef"), WithInvalidUTF8(StrictUTF8))
+
+		line, err := tr.ReadString('\n')
+		assert.Equal(t, "ab", line)
+
+		var e *InvalidUTF8Error
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, []byte{0xff}, e.Bytes)
+		assert.Equal(t, "1:2", tr.Pos().String())
+
+		_, err = tr.Seek(1, io.SeekCurrent)
+		require.NoError(t, err)
+
+		line, err = tr.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "cd\n", line)
+	})
+
+	t.Run("collect", func(t *testing.T) {
+		tr := New(strings.NewReader("ab\xffcd\nef\xe6\x97"), WithInvalidUTF8(CollectInvalidUTF8))
+
+		line, err := tr.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "ab\xffcd\n", line)
+
+		line, err = tr.ReadString('\n')
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, "ef\xe6\x97", line)
+
+		invalid := tr.InvalidUTF8()
+		require.Len(t, invalid, 2)
+		assert.Equal(t, "1:2", invalid[0].Pos.String())
+		assert.Equal(t, "2:2", invalid[1].Pos.String())
+		assert.Equal(t, []byte{0xe6, 0x97}, invalid[1].Bytes)
+	})
+}
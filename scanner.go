@@ -0,0 +1,202 @@
+package textreader
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"github.com/xiam/textreader/position"
+)
+
+// maxEmptyTokens is the number of empty tokens in a row, without moving, that
+// a split function can return before the Scanner gives up on it.
+const maxEmptyTokens = 100
+
+// Scanner splits the text of a TextReader into tokens, like bufio.Scanner
+// does, and keeps track of where every token starts and ends. Split functions
+// written for bufio.Scanner, like bufio.ScanLines or bufio.ScanWords, work
+// unchanged.
+//
+// Unlike a bufio.Scanner wrapping the TextReader, a Scanner doesn't read ahead
+// of the reader's position: after Scan the reader is right after the token, so
+// it can be used directly in between calls. Tokens must fit in the buffer of
+// the reader, see NewWithCapacity and WithMaxCapacity.
+type Scanner struct {
+	tr    *TextReader
+	split bufio.SplitFunc
+
+	token      []byte
+	start, end *position.Position
+
+	err     error // error that stopped the scanner, if any
+	readErr error // error from filling the buffer, the end of the input
+	done    bool
+	empties int // empty tokens in a row without moving
+}
+
+// NewScanner returns a Scanner that reads from tr. It splits the text into
+// lines by default, see Split.
+func NewScanner(tr *TextReader) *Scanner {
+	return &Scanner{
+		tr:    tr,
+		split: bufio.ScanLines,
+	}
+}
+
+// Split sets the split function of the scanner. It must be called before
+// Scan.
+func (s *Scanner) Split(split bufio.SplitFunc) {
+	s.split = split
+}
+
+// Scan advances the scanner to the next token, which is then available
+// through Bytes, Text, Start and End. It returns false when scanning stops,
+// either at the end of the input or at an error, see Err.
+func (s *Scanner) Scan() bool {
+	if s.done {
+		return false
+	}
+
+	t := s.tr
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s.token = nil
+
+	for {
+		data := t.buf[t.r:t.w]
+		atEOF := s.readErr != nil
+
+		if len(data) > 0 || atEOF {
+			advance, token, err := s.split(data, atEOF)
+			if err != nil && !errors.Is(err, bufio.ErrFinalToken) {
+				return s.fail(err)
+			}
+			if advance < 0 {
+				return s.fail(bufio.ErrNegativeAdvance)
+			}
+			if advance > len(data) {
+				return s.fail(bufio.ErrAdvanceTooFar)
+			}
+
+			if cerr := s.advance(data, advance, token); cerr != nil {
+				return s.fail(cerr)
+			}
+
+			if err != nil {
+				// ErrFinalToken.
+				s.done = true
+				return token != nil
+			}
+
+			if token != nil {
+				if advance > 0 {
+					s.empties = 0
+				} else if s.empties++; s.empties > maxEmptyTokens {
+					return s.fail(io.ErrNoProgress)
+				}
+				return true
+			}
+
+			if advance > 0 {
+				continue
+			}
+		}
+
+		if atEOF {
+			s.done = true
+			if !errors.Is(s.readErr, io.EOF) {
+				s.err = t.wrapError("scan", s.readErr)
+			}
+			return false
+		}
+
+		buffered := t.w - t.r
+		if buffered >= t.maxCapacity {
+			return s.fail(bufio.ErrTooLong)
+		}
+
+		if _, err := t.fillAtLeast(buffered + 1); err != nil {
+			s.readErr = err
+		}
+	}
+}
+
+// advance moves the reader over the given number of bytes of data, the
+// buffered text, and takes note of where token starts and ends. Tokens that
+// are not part of data, like the one bufio.ScanRunes returns for invalid
+// input, span all of the bytes moved over.
+func (s *Scanner) advance(data []byte, n int, token []byte) error {
+	t := s.tr
+	defer t.history.clear()
+
+	// Tokens are usually slices of data, see where within it.
+	i := cap(data) - cap(token)
+	if token == nil || i < 0 || i+len(token) > n || len(token) > 0 && &data[:cap(data)][i] != &token[0] {
+		s.start = t.pos.Copy()
+		if _, err := t.consume(n); err != nil {
+			return err
+		}
+		s.end = t.pos.Copy()
+		s.token = token
+		return nil
+	}
+
+	if _, err := t.consume(i); err != nil {
+		return err
+	}
+	s.start = t.pos.Copy()
+
+	if _, err := t.consume(len(token)); err != nil {
+		return err
+	}
+	s.end = t.pos.Copy()
+
+	if _, err := t.consume(n - i - len(token)); err != nil {
+		return err
+	}
+	s.token = token
+
+	return nil
+}
+
+// fail stops the scanner with the given error.
+func (s *Scanner) fail(err error) bool {
+	s.done = true
+	s.token = nil
+	s.err = s.tr.wrapError("scan", err)
+	return false
+}
+
+// Err returns the error that stopped the scanner, or nil if it stopped at the
+// end of the input.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Bytes returns the token found by the last call to Scan. The bytes stop being
+// valid at the next read from the scanner or its reader.
+func (s *Scanner) Bytes() []byte {
+	return s.token
+}
+
+// Text returns the token found by the last call to Scan as a string.
+func (s *Scanner) Text() string {
+	return string(s.token)
+}
+
+// Start returns the position at which the last token starts.
+func (s *Scanner) Start() *position.Position {
+	if s.start == nil {
+		return position.New()
+	}
+	return s.start.Copy()
+}
+
+// End returns the position right after the last token.
+func (s *Scanner) End() *position.Position {
+	if s.end == nil {
+		return position.New()
+	}
+	return s.end.Copy()
+}
@@ -0,0 +1,165 @@
+package textreader
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type token struct {
+	text       string
+	start, end string
+}
+
+func scanAll(t *testing.T, s *Scanner) []token {
+	t.Helper()
+
+	var tokens []token
+	for s.Scan() {
+		tokens = append(tokens, token{s.Text(), s.Start().String(), s.End().String()})
+	}
+	require.NoError(t, s.Err())
+
+	return tokens
+}
+
+func TestScanner(t *testing.T) {
+	text := "héllo  wörld\r\n\t🦄 x\n\nlast"
+
+	testCases := []struct {
+		name     string
+		split    bufio.SplitFunc
+		expected []token
+	}{
+		{"lines", bufio.ScanLines, []token{
+			{"héllo  wörld", "1:0", "1:12"},
+			{"\t🦄 x", "2:0", "2:4"},
+			{"", "3:0", "3:0"},
+			{"last", "4:0", "4:4"},
+		}},
+		{"words", bufio.ScanWords, []token{
+			{"héllo", "1:0", "1:5"},
+			{"wörld", "1:7", "1:12"},
+			{"🦄", "2:1", "2:2"},
+			{"x", "2:3", "2:4"},
+			{"last", "4:0", "4:4"},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, capacity := range []int{16, 0} {
+				s := NewScanner(newReader(text, capacity))
+				s.Split(tc.split)
+
+				assert.Equal(t, tc.expected, scanAll(t, s), "capacity %d", capacity)
+			}
+		})
+	}
+}
+
+func TestScanner_Runes(t *testing.T) {
+	s := NewScanner(New(iotest.OneByteReader(strings.NewReader("a\xff🦄\n"))))
+	s.Split(bufio.ScanRunes)
+
+	assert.Equal(t, []token{
+		{"a", "1:0", "1:1"},
+		{"�", "1:1", "1:2"},
+		{"🦄", "1:2", "1:3"},
+		{"\n", "1:3", "2:0"},
+	}, scanAll(t, s))
+}
+
+func TestScanner_ReaderStaysInSync(t *testing.T) {
+	tr := New(strings.NewReader("key=value\nrest"))
+
+	s := NewScanner(tr)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, '='); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	})
+
+	require.True(t, s.Scan())
+	assert.Equal(t, "key", s.Text())
+	assert.Equal(t, "1:4", tr.Pos().String())
+
+	line, err := tr.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "value\n", line)
+	assert.Equal(t, "2:0", tr.Pos().String())
+}
+
+func TestScanner_Errors(t *testing.T) {
+	t.Run("token too long", func(t *testing.T) {
+		s := NewScanner(newReader(strings.Repeat("x", 20), 8))
+
+		assert.False(t, s.Scan())
+		assert.ErrorIs(t, s.Err(), bufio.ErrTooLong)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		errBroken := errors.New("broken")
+		s := NewScanner(New(io.MultiReader(strings.NewReader("a\nb"), iotest.ErrReader(errBroken))))
+
+		var lines []string
+		for s.Scan() {
+			lines = append(lines, s.Text())
+		}
+		assert.Equal(t, []string{"a", "b"}, lines)
+		assert.ErrorIs(t, s.Err(), errBroken)
+	})
+
+	t.Run("split error", func(t *testing.T) {
+		errSplit := errors.New("split")
+		s := NewScanner(New(strings.NewReader("ab\ncd")))
+		s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			return 0, nil, errSplit
+		})
+
+		assert.False(t, s.Scan())
+		assert.ErrorIs(t, s.Err(), errSplit)
+
+		var e *PosError
+		require.True(t, errors.As(s.Err(), &e))
+		assert.Equal(t, "scan", e.Op)
+	})
+
+	t.Run("final token", func(t *testing.T) {
+		s := NewScanner(New(strings.NewReader("ab\ncd")))
+		s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			return 1, data[:1], bufio.ErrFinalToken
+		})
+
+		assert.Equal(t, []token{{"a", "1:0", "1:1"}}, scanAll(t, s))
+	})
+
+	t.Run("no progress", func(t *testing.T) {
+		s := NewScanner(New(strings.NewReader("ab")))
+		s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			return 0, data[:0], nil
+		})
+
+		for s.Scan() {
+		}
+		assert.ErrorIs(t, s.Err(), io.ErrNoProgress)
+	})
+
+	t.Run("invalid UTF-8", func(t *testing.T) {
+		s := NewScanner(New(strings.NewReader("ab\ncd\xff\n"), WithInvalidUTF8(StrictUTF8)))
+
+		require.True(t, s.Scan())
+		assert.Equal(t, "ab", s.Text())
+
+		assert.False(t, s.Scan())
+		assert.ErrorIs(t, s.Err(), ErrInvalidUTF8)
+	})
+}
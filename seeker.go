@@ -0,0 +1,261 @@
+package textreader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+// seekable reports whether Seek can fall back to the underlying reader
+// for targets outside of the buffer.
+func (t *TextReader) seekable() bool {
+	return t.seeker != nil || t.readerAt != nil
+}
+
+// checkpoint remembers the position at the start of the current line about
+// once every buffer's worth of text, so seekSource has somewhere to start
+// scanning from.
+func (t *TextReader) checkpoint() {
+	if !t.seekable() || t.pos.LineStart() < t.nextCheckpoint {
+		return
+	}
+
+	cp := t.pos.Checkpoint()
+	t.checkpoints = append(t.checkpoints, cp)
+	t.nextCheckpoint = cp.Offset() + t.capacity
+}
+
+// scanLines scans b, stopping at the line breaks where a checkpoint is due.
+func (t *TextReader) scanLines(b []byte) {
+	for t.seekable() {
+		skip := max(t.nextCheckpoint-t.pos.Offset(), 0)
+		if skip >= len(b) {
+			break
+		}
+
+		i := bytes.IndexByte(b[skip:], '\n')
+		if i < 0 {
+			break
+		}
+
+		t.pos.Scan(b[:skip+i+1])
+		t.checkpoint()
+		b = b[skip+i+1:]
+	}
+
+	t.pos.Scan(b)
+	t.checkpoint()
+}
+
+// seekSource moves the reader to the given offset, which is outside of the
+// buffer, by seeking the underlying reader to the closest checkpoint before
+// it and scanning forward from there. Outstanding marks are invalidated.
+func (t *TextReader) seekSource(offset int64) (int64, error) {
+	if !t.seekable() {
+		return 0, ErrSeekOutOfBuffer
+	}
+
+	from := t.pos.Offset()
+	if err := t.jump(int(offset)); err != nil {
+		// Go back to where we were, which we know exists.
+		_ = t.jump(from)
+		return 0, err
+	}
+
+	t.history.clear()
+
+	return int64(t.pos.Offset()), nil
+}
+
+// jump implements seekSource.
+func (t *TextReader) jump(offset int) error {
+	i := sort.Search(len(t.checkpoints), func(i int) bool {
+		return t.checkpoints[i].Offset() > offset
+	}) - 1
+
+	// Scanning forward from where we are is cheaper than going back to a
+	// checkpoint we already passed.
+	if cur := t.pos.Offset(); offset < cur || t.checkpoints[i].Offset() > cur {
+		cp := t.checkpoints[i]
+		if err := t.seekUnderlying(cp.Offset()); err != nil {
+			return err
+		}
+
+		t.r, t.w = 0, 0
+		t.marks = t.marks[:0]
+		t.pos = cp.Copy()
+	}
+
+	for t.pos.Offset() < offset {
+		if _, err := t.fillAtLeast(utf8.UTFMax); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if t.r == t.w {
+			return fmt.Errorf("%w: offset %d is past the end of the input", ErrSeekOutOfBuffer, offset)
+		}
+
+		chunk := t.buf[t.r:t.w]
+		if left := offset - t.pos.Offset(); left < len(chunk) {
+			chunk = chunk[:left]
+		} else if i := lastRuneStart(chunk); i > 0 && !utf8.FullRune(chunk[i:]) {
+			// Don't scan half a rune, the rest of it is on its way.
+			chunk = chunk[:i]
+		}
+
+		t.scan(chunk)
+		t.r += len(chunk)
+	}
+
+	return nil
+}
+
+// seekUnderlying moves the underlying reader to the given offset of the text.
+func (t *TextReader) seekUnderlying(offset int) error {
+	start := t.sourceBase + int64(offset)
+
+	if t.seeker != nil {
+		_, err := t.seeker.Seek(start, io.SeekStart)
+		if err == nil {
+			// We might have been reading at instead, see below.
+			t.br = t.seeker
+			return nil
+		}
+		if t.readerAt == nil {
+			return err
+		}
+	}
+
+	t.br = io.NewSectionReader(t.readerAt, start, math.MaxInt64-start)
+	return nil
+}
+
+// SeekLine moves to the start of the given line, counting from 1. See
+// SeekLineCol.
+func (t *TextReader) SeekLine(line int) (int64, error) {
+	return t.SeekLineCol(line, 0)
+}
+
+// SeekLineCol moves to the given line and column, counted like Pos counts
+// them, and returns the new offset. If the column falls within a character
+// that spans several columns, it moves to the start of that character.
+//
+// Lines before the buffer can only be reached if the underlying reader is
+// seekable, see Seek; otherwise the error matches ErrSeekOutOfBuffer, and so
+// does the error for a line past the end of the input. When it fails, the
+// reader goes back to where it was. With several sources, see MultiReader,
+// lines are counted in the source at the read position.
+//
+// Searching forward never invalidates outstanding marks: if the search would
+// need more data than the mark limit lets them keep, it fails with an error
+// that matches ErrBufferTooSmall instead. Unless the underlying reader is
+// seekable, the same goes for the data at the read position, which is needed
+// to go back. A seekable reader is sought back to it instead, and if that
+// fails the error says so too.
+func (t *TextReader) SeekLineCol(line, col int) (_ int64, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("seek", err) }()
+
+	if line < 1 || col < 0 {
+		return 0, fmt.Errorf("textreader: invalid line %d, column %d", line, col)
+	}
+
+	// Keep what we have buffered around, so we can come back if we don't
+	// find the target.
+	from := t.pos.Offset()
+	id := t.pin(from)
+	defer t.unpin(id)
+
+	if err := t.seekLineCol(line, col, id); err != nil {
+		if _, restoreErr := t.seek(int64(from), io.SeekStart); restoreErr != nil {
+			return 0, errors.Join(err, fmt.Errorf("textreader: can't go back to offset %d: %w", from, restoreErr))
+		}
+		return 0, err
+	}
+
+	t.history.clear()
+
+	return int64(t.pos.Offset()), nil
+}
+
+// seekLineCol implements SeekLineCol. The mark with the given id is the one
+// SeekLineCol pins the buffer with, which is the only one it may invalidate.
+func (t *TextReader) seekLineCol(line, col int, id uint64) error {
+	before := func() bool {
+		l, c := t.pos.Line(), t.pos.Column()
+		return line < l || line == l && col < c
+	}
+
+	if before() {
+		// Start over from the beginning of the buffer, or from the last
+		// checkpoint before the target if it's not in the buffer.
+		if _, err := t.seek(int64(t.pos.Offset()-t.r), io.SeekStart); err != nil {
+			return err
+		}
+
+		if before() {
+			if !t.seekable() {
+				return fmt.Errorf("%w: line %d was already discarded", ErrSeekOutOfBuffer, line)
+			}
+
+			i := sort.Search(len(t.checkpoints), func(i int) bool {
+				return t.checkpoints[i].Line() > line
+			}) - 1
+			if err := t.jump(t.checkpoints[i].Offset()); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		l, c := t.pos.Line(), t.pos.Column()
+
+		if t.dropsMarks(utf8.UTFMax, id) {
+			return fmt.Errorf("%w: line %d is too far ahead to keep outstanding marks", ErrBufferTooSmall, line)
+		}
+		// Only a seekable reader can take us back once the data at the read
+		// position is dropped.
+		if !t.seekable() && t.dropsMarks(utf8.UTFMax, 0) {
+			return fmt.Errorf("%w: line %d is too far ahead to go back from", ErrBufferTooSmall, line)
+		}
+
+		_, size, err := t.nextRune()
+		if errors.Is(err, io.EOF) {
+			switch {
+			case l == line && c == col:
+				return nil
+			case l < line:
+				return fmt.Errorf("%w: line %d is past the end of the input", ErrSeekOutOfBuffer, line)
+			}
+			return fmt.Errorf("textreader: column %d is past the end of line %d", col, line)
+		}
+		if err != nil {
+			return err
+		}
+
+		t.scan(t.buf[t.r : t.r+size])
+		t.r += size
+
+		if next := t.pos.Line(); next < line || next == line && t.pos.Column() <= col {
+			continue
+		}
+
+		// The rune took us past the target. That's fine if it ends the line
+		// right at it or spans several columns, then the target is right before
+		// the rune.
+		if l != line || c < col && t.pos.Line() > line {
+			return fmt.Errorf("textreader: column %d is past the end of line %d", col, line)
+		}
+
+		if err := t.pos.Rewind(size, 1); err != nil {
+			return err
+		}
+		t.r -= size
+
+		return nil
+	}
+}
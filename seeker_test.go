@@ -0,0 +1,381 @@
+package textreader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiam/textreader/position"
+)
+
+func seekText() string {
+	var sb strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&sb, "line %d: héllo\twörld 🦄\n", i)
+	}
+	return sb.String()
+}
+
+func TestSeek_RandomAccess(t *testing.T) {
+	text := seekText()
+
+	testCases := []struct {
+		name   string
+		reader func() io.Reader
+	}{
+		{"io.Seeker", func() io.Reader { return strings.NewReader(text) }},
+		{"io.ReaderAt only", func() io.Reader {
+			return struct {
+				io.ReaderAt
+				io.Reader
+			}{strings.NewReader(text), strings.NewReader(text)}
+		}},
+		{"io.ReaderAt with a broken io.Seeker", func() io.Reader {
+			return struct {
+				io.ReaderAt
+				brokenSeeker
+			}{strings.NewReader(text), brokenSeeker{strings.NewReader(text)}}
+		}},
+	}
+
+	offsets := []int{len(text), 0, 1000, 999, 5, len(text) - 1, 2500, 2, len(text) / 2}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := NewWithCapacity(tc.reader(), 64, WithRandomAccess())
+
+			_, err := io.Copy(io.Discard, tr)
+			require.NoError(t, err)
+			assert.Greater(t, len(tr.checkpoints), len(text)/128)
+
+			for _, offset := range offsets {
+				n, err := tr.Seek(int64(offset), io.SeekStart)
+				require.NoError(t, err)
+				assert.Equal(t, int64(offset), n)
+
+				expected := position.New()
+				expected.Scan([]byte(text[:offset]))
+				assert.Equal(t, expected.String(), tr.Pos().String(), "at %d", offset)
+
+				rest, err := io.ReadAll(tr)
+				require.NoError(t, err)
+				assert.Equal(t, text[offset:], string(rest), "at %d", offset)
+				assert.Equal(t, len(text), tr.Pos().Offset())
+			}
+		})
+	}
+}
+
+func TestSeek_NoRandomAccess(t *testing.T) {
+	text := seekText()
+
+	// Without WithRandomAccess, seekable sources are read like plain streams.
+	tr := NewWithCapacity(strings.NewReader(text), 64)
+	_, err := io.Copy(io.Discard, tr)
+	require.NoError(t, err)
+	assert.Empty(t, tr.checkpoints)
+
+	_, err = tr.Seek(0, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekOutOfBuffer)
+	assert.Equal(t, len(text), tr.Pos().Offset())
+}
+
+func TestSeek_RandomAccessRelative(t *testing.T) {
+	text := seekText()
+
+	tr := NewWithCapacity(strings.NewReader(text), 32, WithRandomAccess())
+
+	_, err := tr.Seek(1000, io.SeekCurrent)
+	require.NoError(t, err)
+
+	_, err = tr.Seek(-900, io.SeekCurrent)
+	require.NoError(t, err)
+
+	r, _, err := tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, rune(text[100]), r)
+
+	expected := position.New()
+	expected.Scan([]byte(text[:101]))
+	assert.Equal(t, expected.String(), tr.Pos().String())
+}
+
+func TestSeek_SourceOffset(t *testing.T) {
+	r := strings.NewReader("#!header\nab\ncd\nef\n")
+
+	_, err := r.Seek(9, io.SeekStart)
+	require.NoError(t, err)
+
+	tr := NewWithCapacity(r, 4, WithRandomAccess())
+
+	_, err = io.Copy(io.Discard, tr)
+	require.NoError(t, err)
+
+	_, err = tr.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	assert.Equal(t, "2:1", tr.Pos().String())
+
+	rest, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, "d\nef\n", string(rest))
+}
+
+func TestSeek_PastEnd(t *testing.T) {
+	text := seekText()
+
+	tr := NewWithCapacity(strings.NewReader(text), 32, WithRandomAccess())
+
+	_, err := tr.Seek(100, io.SeekStart)
+	require.NoError(t, err)
+
+	_, err = tr.Seek(int64(len(text)+1), io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekOutOfBuffer)
+	assert.Equal(t, 100, tr.Pos().Offset())
+
+	rest, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, text[100:], string(rest))
+}
+
+func TestSeek_NotRandomAccess(t *testing.T) {
+	text := seekText()
+
+	tr := NewWithCapacity(iotest.HalfReader(bytes.NewReader([]byte(text))), 32)
+
+	_, err := io.Copy(io.Discard, tr)
+	require.NoError(t, err)
+
+	_, err = tr.Seek(0, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekOutOfBuffer)
+}
+
+func TestSeekLineCol(t *testing.T) {
+	text := "ab\ncd\n\tef\n"
+
+	tr := New(iotest.HalfReader(strings.NewReader(text)))
+
+	testCases := []struct {
+		line, col int
+		offset    int
+	}{
+		{2, 1, 4},
+		{1, 0, 0},
+		{3, 2, 8},
+		{1, 2, 2},
+		{4, 0, len(text)},
+		{3, 0, 6},
+	}
+
+	for _, tc := range testCases {
+		n, err := tr.SeekLineCol(tc.line, tc.col)
+		require.NoError(t, err)
+		assert.Equal(t, int64(tc.offset), n)
+
+		pos := tr.Pos()
+		assert.Equal(t, tc.line, pos.Line())
+		assert.Equal(t, tc.col, pos.Column())
+		assert.Equal(t, tc.offset, pos.Offset())
+
+		rest, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		assert.Equal(t, text[tc.offset:], string(rest))
+	}
+
+	n, err := tr.SeekLine(2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	r, _, err := tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, 'c', r)
+}
+
+func TestSeekLineCol_Errors(t *testing.T) {
+	text := "ab\ncd\n\nef"
+
+	tr := New(strings.NewReader(text))
+
+	_, err := tr.SeekLineCol(2, 1)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		line, col int
+		outOfBuf  bool
+	}{
+		{"column past the end of the line", 1, 3, false},
+		{"column on an empty line", 3, 1, false},
+		{"column past the end of the input", 4, 3, false},
+		{"line past the end of the input", 5, 0, true},
+		{"invalid line", 0, 0, false},
+		{"invalid column", 1, -1, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tr.SeekLineCol(tc.line, tc.col)
+			require.Error(t, err)
+			assert.Equal(t, tc.outOfBuf, errors.Is(err, ErrSeekOutOfBuffer))
+			assert.Equal(t, "2:1", tr.Pos().String())
+		})
+	}
+
+	r, _, err := tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, 'd', r)
+}
+
+func TestSeekLineCol_Marks(t *testing.T) {
+	text := strings.Repeat("line\n", 20)
+
+	// A plain stream, so that seeking can't fall back on the source.
+	stream := func(s string) io.Reader {
+		return struct{ io.Reader }{strings.NewReader(s)}
+	}
+
+	t.Run("marks survive a failed search", func(t *testing.T) {
+		tr := NewWithCapacity(stream(text), 8, WithMarkLimit(16))
+		_, err := io.ReadFull(tr, make([]byte, 2))
+		require.NoError(t, err)
+
+		m := tr.Mark()
+		_, err = io.ReadFull(tr, make([]byte, 5))
+		require.NoError(t, err)
+
+		_, err = tr.SeekLineCol(50, 0)
+		assert.ErrorIs(t, err, ErrBufferTooSmall)
+		assert.Equal(t, 7, tr.Pos().Offset())
+
+		require.NoError(t, tr.Reset(m))
+		assert.Equal(t, 2, tr.Pos().Offset())
+
+		// Once released, the search can go as far as the mark limit lets it.
+		tr.Release(m)
+		n, err := tr.SeekLineCol(3, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), n)
+	})
+
+	t.Run("plain readers stay where they were", func(t *testing.T) {
+		tr := NewWithCapacity(stream(text), 8)
+		_, err := io.ReadFull(tr, make([]byte, 2))
+		require.NoError(t, err)
+
+		// Going further than the buffer can hold would lose the way back.
+		_, err = tr.SeekLineCol(50, 0)
+		assert.ErrorIs(t, err, ErrBufferTooSmall)
+		assert.Equal(t, "1:2", tr.Pos().String())
+		assert.Equal(t, 2, tr.Pos().Offset())
+
+		rest, err := readAllRunes(tr)
+		require.NoError(t, err)
+		assert.Equal(t, text[2:], rest)
+
+		// A line past the end of a short input.
+		tr = NewWithCapacity(stream("ab\ncd"), 8)
+		_, err = io.ReadFull(tr, make([]byte, 4))
+		require.NoError(t, err)
+
+		_, err = tr.SeekLineCol(5, 0)
+		assert.ErrorIs(t, err, ErrSeekOutOfBuffer)
+		assert.Equal(t, "2:1", tr.Pos().String())
+		assert.Equal(t, 4, tr.Pos().Offset())
+	})
+
+	t.Run("failing to go back is reported", func(t *testing.T) {
+		tr := NewWithCapacity(brokenSeeker{strings.NewReader(text)}, 8, WithRandomAccess())
+		_, err := io.ReadFull(tr, make([]byte, 2))
+		require.NoError(t, err)
+
+		_, err = tr.SeekLineCol(50, 0)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrSeekOutOfBuffer)
+		assert.Contains(t, err.Error(), "can't go back to offset 2")
+	})
+}
+
+// brokenSeeker can tell where it is, but fails to seek anywhere.
+type brokenSeeker struct{ io.Reader }
+
+func (brokenSeeker) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekCurrent {
+		return 0, nil
+	}
+	return 0, errors.New("broken seeker")
+}
+
+func TestSeekLineCol_Endings(t *testing.T) {
+	tr := New(strings.NewReader("ab\r\ncd"), WithPositionOptions(position.WithLineEndings(position.CRLF)))
+
+	n, err := tr.SeekLineCol(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = tr.SeekLineCol(2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	// The "\r" is part of the line until the "\n" is read.
+	n, err = tr.SeekLineCol(1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	_, err = tr.SeekLineCol(1, 4)
+	assert.Error(t, err)
+}
+
+func TestSeekLineCol_Wide(t *testing.T) {
+	tr := New(strings.NewReader("a日b"), WithPositionOptions(position.WithColumnMode(position.Cells)))
+
+	n, err := tr.SeekLineCol(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 1, tr.Pos().Column())
+
+	n, err = tr.SeekLineCol(1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("a日")), n)
+}
+
+func TestSeekLineCol_Discarded(t *testing.T) {
+	text := seekText()
+
+	tr := NewWithCapacity(iotest.HalfReader(strings.NewReader(text)), 32)
+
+	_, err := io.Copy(io.Discard, tr)
+	require.NoError(t, err)
+
+	_, err = tr.SeekLine(1)
+	assert.ErrorIs(t, err, ErrSeekOutOfBuffer)
+	assert.Equal(t, len(text), tr.Pos().Offset())
+}
+
+func TestSeekLineCol_RandomAccess(t *testing.T) {
+	text := seekText()
+
+	tr := NewWithCapacity(strings.NewReader(text), 32, WithRandomAccess())
+
+	_, err := io.Copy(io.Discard, tr)
+	require.NoError(t, err)
+
+	for _, line := range []int{1, 150, 42, 43, 200, 7} {
+		prefix := fmt.Sprintf("line %d: h", line-1)
+
+		n, err := tr.SeekLineCol(line, len(prefix))
+		require.NoError(t, err)
+
+		offset := strings.Index(text, prefix) + len(prefix)
+		assert.Equal(t, int64(offset), n)
+		assert.Equal(t, fmt.Sprintf("%d:%d", line, len(prefix)), tr.Pos().String())
+
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 'é', r)
+	}
+}
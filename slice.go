@@ -0,0 +1,68 @@
+package textreader
+
+import (
+	"fmt"
+
+	"github.com/xiam/textreader/position"
+)
+
+// Bytes returns the buffered bytes that have not been read yet, without
+// advancing the reader or its position. It doesn't read from the underlying
+// reader, use Peek for that. The bytes stop being valid at the next read, seek
+// or reset.
+func (t *TextReader) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.buf[t.r:t.w]
+}
+
+// Slice returns the buffered bytes between the given input offsets, as
+// reported by Pos().Offset(), without copying them or moving the reader. Both
+// offsets must be within the buffered data, which includes the data pinned by
+// marks (see Mark) and the look-behind window (see WithLookBehind); otherwise
+// the error matches ErrOutOfBuffer. The bytes stop being valid at the next
+// read, seek or reset.
+func (t *TextReader) Slice(start, end int) (_ []byte, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("slice", err) }()
+
+	return t.slice(start, end)
+}
+
+// Token returns the bytes read since the given marker was created, along with
+// the span they cover, without copying them. Lexers can mark the start of a
+// token, read it with ReadRune or Peek, and take it with Token before
+// releasing the marker. The bytes stop being valid at the next read, seek or
+// reset, and the error is ErrInvalidMark if the marker is no longer valid.
+func (t *TextReader) Token(m Marker) (_ []byte, _ position.Span, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("token", err) }()
+
+	if t.findMark(m.id) < 0 {
+		return nil, position.Span{}, ErrInvalidMark
+	}
+
+	b, err := t.slice(m.pos.Offset(), t.pos.Offset())
+	if err != nil {
+		return nil, position.Span{}, err
+	}
+
+	return b, position.Span{Start: m.pos.Copy(), End: t.pos.Copy()}, nil
+}
+
+// slice implements Slice.
+func (t *TextReader) slice(start, end int) ([]byte, error) {
+	if start > end {
+		return nil, fmt.Errorf("invalid slice: start %d is after end %d", start, end)
+	}
+
+	first := t.pos.Offset() - t.r
+	if start < first || end > first+t.w {
+		return nil, fmt.Errorf("%w: [%d, %d) is not within [%d, %d)", ErrOutOfBuffer, start, end, first, first+t.w)
+	}
+
+	return t.buf[start-first : end-first], nil
+}
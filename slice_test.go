@@ -0,0 +1,92 @@
+package textreader
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytes(t *testing.T) {
+	tr := newReader("hello world", 8)
+	assert.Empty(t, tr.Bytes())
+
+	_, err := tr.Peek(4)
+	require.NoError(t, err)
+	assert.Equal(t, "hello wo", string(tr.Bytes()))
+
+	_, _, err = tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, "ello wo", string(tr.Bytes()))
+	assert.Equal(t, "1:1", tr.Pos().String())
+}
+
+func TestSlice(t *testing.T) {
+	tr := newReader("let answer = 42", 0)
+
+	_, err := tr.Peek(15)
+	require.NoError(t, err)
+
+	b, err := tr.Slice(4, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "answer", string(b))
+	assert.Equal(t, 0, tr.Pos().Offset())
+
+	_, err = tr.Slice(10, 16)
+	assert.ErrorIs(t, err, ErrOutOfBuffer)
+
+	_, err = tr.Slice(10, 4)
+	assert.Error(t, err)
+
+	// Data discarded from the buffer is out of reach.
+	tr = newReader(strings.Repeat("abcd", 8), 8)
+	for i := 0; i < 20; i++ {
+		_, _, err := tr.ReadRune()
+		require.NoError(t, err)
+	}
+	_, err = tr.Slice(0, 4)
+	assert.ErrorIs(t, err, ErrOutOfBuffer)
+}
+
+func TestToken(t *testing.T) {
+	tr := newReader("sum := alpha + beta\nπ := 3.14", 8)
+
+	var words, spans []string
+	for {
+		r, _, err := tr.PeekRune(0)
+		if err != nil {
+			break
+		}
+		if !unicode.IsLetter(r) {
+			_, _, err = tr.ReadRune()
+			require.NoError(t, err)
+			continue
+		}
+
+		m := tr.Mark()
+		for {
+			r, _, err := tr.PeekRune(0)
+			if err != nil || !unicode.IsLetter(r) {
+				break
+			}
+			_, _, err = tr.ReadRune()
+			require.NoError(t, err)
+		}
+
+		word, span, err := tr.Token(m)
+		require.NoError(t, err)
+		words = append(words, string(word))
+		spans = append(spans, span.String())
+		tr.Release(m)
+	}
+
+	assert.Equal(t, []string{"sum", "alpha", "beta", "π"}, words)
+	assert.Equal(t, []string{"1:0-1:3", "1:7-1:12", "1:15-1:19", "2:0-2:1"}, spans)
+
+	m := tr.Mark()
+	tr.Release(m)
+	_, _, err := tr.Token(m)
+	assert.ErrorIs(t, err, ErrInvalidMark)
+}
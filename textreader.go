@@ -21,47 +21,192 @@ var (
 	ErrBufferTooSmall  = errors.New("buffer too small")
 	ErrInvalidUTF8     = errors.New("invalid UTF-8 encoding")
 	ErrSeekOutOfBuffer = errors.New("seek out of buffer")
+	ErrInvalidMark     = errors.New("invalid mark")
+	ErrLineTruncated   = errors.New("line truncated")
+	ErrOutOfBuffer     = errors.New("out of buffer")
 )
 
 // TextReader reads from an io.Reader, buffering data and keeping track of the
-// current position (line, column, and offset) in the text stream. It supports
-// seeking only within the currently buffered data, which is good enough for
-// giving context of the text around the current read position.
+// current position (line, column, and offset) in the text stream. Plain
+// streams can only be seeked within the currently buffered data, which is good
+// enough for giving context of the text around the current read position.
+// Sources that are an io.Seeker or an io.ReaderAt, like an *os.File, can be
+// seeked anywhere with WithRandomAccess, see Seek.
 type TextReader struct {
 	br io.Reader
 	mu sync.Mutex
 
 	pos *position.Position
 
-	lastRuneSize int
+	history runeHistory
 
-	capacity int
-	buf      []byte
+	capacity    int
+	maxCapacity int // size the buffer may grow to for a single read, see WithMaxCapacity
+	buf         []byte
+
+	marks     []mark
+	lastMark  uint64
+	markLimit int
+
+	lookBehind int // bytes of read data kept in front of the read pointer, see WithLookBehind
+
+	unreadDepth int
+	posOpts     []position.Option
+
+	encoding Encoding
+	dec      *decoder
+
+	multi      *multiReader
+	source     int        // index of the last source of multi seen
+	boundaries []boundary // boundaries between sources that may be scanned
+
+	randomAccess   bool // whether to seek the source outside of the buffer, see WithRandomAccess
+	seeker         io.ReadSeeker
+	readerAt       io.ReaderAt
+	sourceBase     int64                // offset of the underlying reader at which the text starts
+	checkpoints    []*position.Position // line starts to seek from, see seekSource
+	nextCheckpoint int
+
+	invalidMode InvalidUTF8Mode
+	invalid     []*InvalidUTF8Error // invalid sequences found in CollectInvalidUTF8 mode
+	checked     int                 // offset up to which invalid sequences were collected
 
 	r int
 	w int
 }
 
+// Option configures a TextReader.
+type Option func(*TextReader)
+
+// WithMarkLimit sets the maximum size the buffer is allowed to grow to in
+// order to keep the data after outstanding marks, see Mark. By default the
+// buffer never grows beyond its capacity, or the size set with
+// WithMaxCapacity.
+func WithMarkLimit(n int) Option {
+	return func(t *TextReader) {
+		t.markLimit = n
+	}
+}
+
+// WithMaxCapacity lets the buffer grow beyond its capacity when a call needs
+// more room, like a Peek of more bytes than the capacity, a line longer than it
+// or data kept for outstanding marks. The buffer doubles in size as needed, up
+// to n bytes, and shrinks back to its capacity once the data fits in it again.
+// By default the buffer doesn't grow, except for marks (see WithMarkLimit).
+// The look-behind window (see WithLookBehind) is kept on top of n.
+func WithMaxCapacity(n int) Option {
+	return func(t *TextReader) {
+		t.maxCapacity = n
+	}
+}
+
+// WithLookBehind makes the buffer keep at least n bytes of the data already
+// read in front of the read position, so that seeking back over them and
+// showing the text before the position, as in Context, always work. The kept
+// data starts at a rune boundary, and at the start of a line (see
+// position.WithLineEndings) when that's no more than n bytes further back. The
+// window is kept on top of the capacity of the buffer, and of the sizes set
+// with WithMaxCapacity and WithMarkLimit, so the buffer may hold up to about
+// 2n more bytes than those. Reads larger
+// than the buffer go through it instead of straight into the caller's slice.
+func WithLookBehind(n int) Option {
+	return func(t *TextReader) {
+		t.lookBehind = max(n, 0)
+	}
+}
+
+// WithRandomAccess lets Seek and SeekLineCol reach data outside of the buffer
+// when the underlying reader is an io.Seeker or an io.ReaderAt, like an
+// *os.File, by seeking it. To know where lines start, the reader then keeps a
+// copy of the position about once every buffer's worth of text, so memory
+// grows with the length of the input. It has no effect on other readers.
+func WithRandomAccess() Option {
+	return func(t *TextReader) {
+		t.randomAccess = true
+	}
+}
+
+// WithUnreadDepth sets how many runes can be unread in a row with
+// UnreadRune. The default is 1.
+func WithUnreadDepth(n int) Option {
+	return func(t *TextReader) {
+		t.unreadDepth = n
+	}
+}
+
+// WithPositionOptions sets the options used to create the position tracker,
+// for instance position.WithTabWidth.
+func WithPositionOptions(opts ...position.Option) Option {
+	return func(t *TextReader) {
+		t.posOpts = append(t.posOpts, opts...)
+	}
+}
+
 // New returns a new TextReader that reads from r with the default buffer
 // capacity.
-func New(r io.Reader) *TextReader {
-	return NewWithCapacity(r, defaultCapacity)
+func New(r io.Reader, opts ...Option) *TextReader {
+	return NewWithCapacity(r, defaultCapacity, opts...)
 }
 
 // NewWithCapacity returns a new TextReader with a buffer of at least the
 // specified capacity.
-func NewWithCapacity(r io.Reader, capacity int) *TextReader {
+func NewWithCapacity(r io.Reader, capacity int, opts ...Option) *TextReader {
 	if capacity < utf8.UTFMax {
 		capacity = utf8.UTFMax
 	}
 
-	return &TextReader{
-		br:           r,
-		buf:          make([]byte, capacity),
-		pos:          position.New(),
-		capacity:     capacity,
-		lastRuneSize: -1,
+	t := &TextReader{
+		br:       r,
+		capacity: capacity,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	t.buf = make([]byte, capacity+t.lookBehind)
+
+	if m, ok := r.(*multiReader); ok && t.encoding == UTF8 {
+		t.multi = m
+		if len(m.sources) > 0 {
+			t.posOpts = append([]position.Option{position.WithName(m.sources[0].Name)}, t.posOpts...)
+		}
+	}
+
+	t.pos = position.New(t.posOpts...)
+
+	if t.encoding != UTF8 {
+		t.dec = newDecoder(r, t.encoding)
+		t.br = t.dec
+	}
+
+	if t.randomAccess && t.multi == nil && t.dec == nil {
+		if src, ok := r.(io.ReadSeeker); ok {
+			if base, err := src.Seek(0, io.SeekCurrent); err == nil {
+				t.seeker, t.sourceBase = src, base
+			}
+		}
+		// Sources that can do both are read at if they fail to seek.
+		if src, ok := r.(io.ReaderAt); ok {
+			t.readerAt = src
+		}
+
+		if t.seekable() {
+			t.checkpoints = append(t.checkpoints, t.pos.Checkpoint())
+			t.nextCheckpoint = capacity
+		}
+	}
+
+	if t.maxCapacity < capacity {
+		t.maxCapacity = capacity
 	}
+	if t.markLimit < t.maxCapacity {
+		t.markLimit = t.maxCapacity
+	}
+
+	t.history = newRuneHistory(t.unreadDepth)
+
+	return t
 }
 
 func (t *TextReader) fillAtLeast(n int) (bool, error) {
@@ -74,11 +219,14 @@ func (t *TextReader) fillAtLeast(n int) (bool, error) {
 		return true, nil
 	}
 
-	if n > t.capacity {
-		// The requested read is larger than the buffer this is not allowed.
+	if n > t.maxCapacity {
+		// The requested read is larger than the buffer can grow, this is not
+		// allowed.
 		return false, ErrBufferTooSmall
 	}
 
+	t.sniff()
+
 	// If we already have enough data in the buffer, just return.
 	if n <= t.w-t.r {
 		return true, nil
@@ -86,12 +234,8 @@ func (t *TextReader) fillAtLeast(n int) (bool, error) {
 
 	// The next read will be beyond the buffer, so we need to shrink the buffer
 	// to the current read position to free up space.
-	if t.r+n >= t.capacity {
-
-		copy(t.buf[0:], t.buf[t.r:t.w])
-
-		t.w = t.w - t.r
-		t.r = 0
+	if t.r+n >= len(t.buf) {
+		t.compact(n)
 	}
 
 	var readErr error
@@ -99,7 +243,7 @@ func (t *TextReader) fillAtLeast(n int) (bool, error) {
 	for t.w-t.r < n && readErr == nil {
 		var bytesRead int
 
-		bytesRead, readErr = t.br.Read(t.buf[t.w:t.capacity])
+		bytesRead, readErr = t.readSource(t.buf[t.w:], t.pos.Offset()-t.r+t.w)
 		t.w += bytesRead
 
 		if bytesRead == 0 && readErr == nil {
@@ -114,70 +258,203 @@ func (t *TextReader) fillAtLeast(n int) (bool, error) {
 	return t.w-t.r >= n, readErr
 }
 
+// sniff lets the decoder settle the encoding of the input, if it didn't yet,
+// and tells the position about it. It must be called before anything is
+// scanned.
+func (t *TextReader) sniff() {
+	if t.dec == nil || t.dec.sniffed {
+		return
+	}
+
+	t.dec.sniff()
+	t.pos = position.New(append(t.posOpts, t.dec.source())...)
+}
+
+// compact moves the data that is still needed to the beginning of the buffer,
+// making room for at least n unread bytes. Data before the read pointer is
+// discarded unless it is pinned by a mark or is part of the look-behind
+// window, see WithLookBehind. The buffer grows as needed, up to
+// the mark limit, and goes back to its capacity once the data fits in it.
+func (t *TextReader) compact(n int) {
+	keep := t.keep()
+
+	// Give up on the oldest marks until what they pin fits within the limit.
+	for keep < t.r && t.r-keep+n > t.markLimit {
+		t.dropMark()
+		keep = t.keep()
+	}
+
+	keep = min(keep, t.behind())
+
+	size := t.capacity + t.lookBehind
+	if need := t.r - keep + n; need > size {
+		size = len(t.buf)
+		for size < need {
+			size *= 2
+		}
+		if size > max(t.markLimit, need) {
+			size = max(t.markLimit, need)
+		}
+	}
+
+	if size != len(t.buf) && t.w-keep <= size {
+		buf := make([]byte, size)
+		copy(buf, t.buf[keep:t.w])
+		t.buf = buf
+	} else {
+		copy(t.buf[0:], t.buf[keep:t.w])
+	}
+
+	t.w = t.w - keep
+	t.r = t.r - keep
+
+	// Runes that were shifted out of the buffer can't be unread anymore.
+	t.history.trim(t.r)
+
+	// Everything before the buffer is out of reach now.
+	t.pos.Discard(t.pos.Offset() - t.r)
+	t.dropBoundaries(t.pos.Offset() - t.r)
+}
+
 // ReadRune reads a single UTF-8 encoded Unicode character and returns the rune
-// and its size in bytes.
+// and its size in bytes. Invalid input is read as utf8.RuneError, unless the
+// reader is in StrictUTF8 mode, see WithInvalidUTF8.
 func (t *TextReader) ReadRune() (r rune, size int, err error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("read rune", err) }()
 
-	// Try to fill the buffer with at least enough bytes for a maximal rune.
-	// We can tolerate an io.EOF here, as we might have a partial buffer to read from.
-	_, err = t.fillAtLeast(utf8.UTFMax)
-	if err != nil && !errors.Is(err, io.EOF) {
+	r, size, err = t.nextRune()
+	if err != nil {
 		return 0, 0, err
 	}
 
-	// If the buffer is empty after trying to fill, we are at the end of the stream.
-	if t.r >= t.w {
-		return 0, 0, io.EOF
-	}
-
-	// Let utf8.DecodeRune handle all cases: valid ASCII, valid multi-byte,
-	// and invalid UTF-8 sequences.
-	// If the sequence is invalid, it returns (utf8.RuneError, 1).
-	r, size = utf8.DecodeRune(t.buf[t.r:t.w])
-
 	// Advance the reader's position. This is crucial.
 	// For an invalid byte, size will be 1, allowing us to skip it and continue.
-	t.pos.Scan(t.buf[t.r : t.r+size])
+	t.scan(t.buf[t.r : t.r+size])
 	t.r += size
 
 	// Update state to allow for UnreadRune.
-	t.lastRuneSize = size
+	t.history.push(size)
 
 	// The error is nil because we successfully "read" a rune from the stream,
-	// even if that rune is the replacement/error character. The caller is
-	// responsible for checking if r == utf8.RuneError.
+	// even if that rune is the replacement/error character (nextRune already
+	// failed on it in StrictUTF8 mode). The caller is responsible for checking
+	// if r == utf8.RuneError.
 	return r, size, nil
 }
 
+// Peek returns the next n bytes without advancing the reader or its position.
+// The bytes stop being valid at the next read call. If Peek returns fewer than
+// n bytes, it also returns an error explaining why the read is short. The
+// error is ErrBufferTooSmall if n is larger than the buffer capacity, or the
+// size set with WithMaxCapacity.
+func (t *TextReader) Peek(n int) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ok, err := t.fillAtLeast(n)
+	if n < 0 {
+		return nil, err
+	}
+
+	if !ok {
+		end := t.r + n
+		if end > t.w {
+			end = t.w
+		}
+
+		return t.buf[t.r:end], err
+	}
+
+	return t.buf[t.r : t.r+n], nil
+}
+
+// PeekRune returns the k-th rune ahead of the current read position and its
+// size in bytes, without advancing the reader or its position. PeekRune(0)
+// returns the rune the next call to ReadRune would return. Invalid UTF-8
+// sequences are reported as utf8.RuneError with size 1, just like ReadRune
+// does. The error is ErrBufferTooSmall if the requested rune is further ahead
+// than what the buffer can hold.
+func (t *TextReader) PeekRune(k int) (r rune, size int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if k < 0 {
+		return 0, 0, fmt.Errorf("invalid rune index: %d", k)
+	}
+
+	// Offset of the rune we are looking at, relative to the read pointer. We
+	// can't keep absolute indexes since filling may shift the buffer.
+	ahead := 0
+
+	for i := 0; ; i++ {
+		if !utf8.FullRune(t.buf[t.r+ahead : t.w]) {
+			if ahead+1 > t.maxCapacity {
+				return 0, 0, ErrBufferTooSmall
+			}
+
+			// Near the end of the input, or of the buffer, the rune might be
+			// shorter than utf8.UTFMax.
+			_, err = t.fillAtLeast(min(ahead+utf8.UTFMax, t.maxCapacity))
+			if err != nil && !errors.Is(err, io.EOF) {
+				return 0, 0, err
+			}
+			if err == nil && !utf8.FullRune(t.buf[t.r+ahead:t.w]) {
+				// There is more of the rune, but no room for it.
+				return 0, 0, ErrBufferTooSmall
+			}
+		}
+
+		if t.r+ahead >= t.w {
+			return 0, 0, io.EOF
+		}
+
+		r, size = utf8.DecodeRune(t.buf[t.r+ahead : t.w])
+		if i == k {
+			return r, size, nil
+		}
+
+		ahead += size
+	}
+}
+
 // UnreadRune unreads the last rune read by ReadRune. It is an error to call
 // UnreadRune if the most recent method called on the TextReader was not
-// ReadRune.  Only one level of unread is supported.
-func (t *TextReader) UnreadRune() error {
+// ReadRune or UnreadRune. By default only one level of unread is supported,
+// use WithUnreadDepth to be able to unread several runes in a row.
+func (t *TextReader) UnreadRune() (err error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("unread rune", err) }()
 
-	if t.lastRuneSize < 0 || t.r < t.lastRuneSize {
+	size, ok := t.history.last()
+	if !ok || t.r < size {
 		return bufio.ErrInvalidUnreadRune
 	}
 
-	if err := t.pos.Rewind(t.lastRuneSize, 1); err != nil {
+	if err := t.pos.Rewind(size, 1); err != nil {
 		return fmt.Errorf("rewind: %w", err)
 	}
 
-	t.r -= t.lastRuneSize
-	t.lastRuneSize = -1
+	t.r -= size
+	t.history.pop()
 
 	return nil
 }
 
 // Read reads up to len(p) bytes into p and returns the number of bytes read.
 // For reads larger than the buffer capacity, it will read directly from the
-// underlying reader, and discard any previously buffered data.
+// underlying reader, and discard any previously buffered data. See
+// WithInvalidUTF8 for how reads work when the reader checks its input.
 func (t *TextReader) Read(p []byte) (n int, err error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("read", err) }()
+
+	if t.invalidMode != ReplaceInvalidUTF8 {
+		return t.readRunes(p)
+	}
 
 	needed, filled := len(p), 0
 
@@ -196,7 +473,7 @@ func (t *TextReader) Read(p []byte) (n int, err error) {
 			}
 
 			copy(p[filled:], t.buf[t.r:t.r+n])
-			t.pos.Scan(p[filled : filled+n])
+			t.scan(p[filled : filled+n])
 			t.r += n
 
 			filled += n
@@ -207,18 +484,22 @@ func (t *TextReader) Read(p []byte) (n int, err error) {
 		}
 
 		// The size of the requested read is larger than the buffer, there's no way
-		// we can handle this
-		if needed-filled > t.capacity {
+		// we can handle this, unless we have to keep the buffer around for an
+		// outstanding mark or look-behind data to keep.
+		if needed-filled > t.capacity && len(t.marks) == 0 && t.lookBehind == 0 {
 
 			// Read remaining data directly into p
-			n, readErr = t.br.Read(p[filled:])
+			t.sniff()
+			n, readErr = t.readSource(p[filled:], t.pos.Offset())
 
-			t.pos.Scan(p[filled : filled+n])
+			t.scan(p[filled : filled+n])
 
 			// Reset the buffer since we dumped it all into
 			t.r = 0
 			t.w = 0
-			t.lastRuneSize = -1
+			t.history.clear()
+			t.pos.Discard(t.pos.Offset())
+			t.dropBoundaries(t.pos.Offset())
 
 			filled += n
 
@@ -226,10 +507,10 @@ func (t *TextReader) Read(p []byte) (n int, err error) {
 		}
 
 		// Fill the buffer with more data for the next read
-		_, readErr = t.fillAtLeast(needed - filled)
+		_, readErr = t.fillAtLeast(min(needed-filled, t.capacity))
 	}
 
-	t.lastRuneSize = -1
+	t.history.clear()
 
 	if filled == 0 && readErr != nil {
 		return 0, readErr
@@ -245,10 +526,21 @@ func (t *TextReader) Read(p []byte) (n int, err error) {
 // buffer. An attempt to seek to a position before the start of the current
 // buffer will result in an ErrSeekOutOfBuffer.  It does not perform a seek on
 // the underlying io.Reader.
-func (t *TextReader) Seek(offset int64, whence int) (int64, error) {
+//
+// That is, unless the underlying reader is an io.Seeker or an io.ReaderAt,
+// like an *os.File, and WithRandomAccess is set. Then targets outside of the buffer are reached by seeking
+// the underlying reader to a known line start before the target and scanning
+// forward from there, which invalidates outstanding marks.
+func (t *TextReader) Seek(offset int64, whence int) (_ int64, err error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	defer func() { err = t.wrapError("seek", err) }()
+
+	return t.seek(offset, whence)
+}
 
+// seek implements Seek.
+func (t *TextReader) seek(offset int64, whence int) (int64, error) {
 	var newR int64 // new read pointer relative to start of t.buf
 
 	switch whence {
@@ -260,9 +552,10 @@ func (t *TextReader) Seek(offset int64, whence int) (int64, error) {
 		// Calculate the absolute stream offset that corresponds to the start of our buffer.
 		bufferStartOffset := int64(t.pos.Offset() - t.r)
 
-		// If the target absolute offset is before the start of our buffered data, we cannot seek there.
+		// If the target absolute offset is before the start of our buffered
+		// data, only the underlying reader can take us there.
 		if offset < bufferStartOffset {
-			return 0, ErrSeekOutOfBuffer
+			return t.seekSource(offset)
 		}
 
 		// Calculate new read pointer relative to the buffer.
@@ -276,8 +569,10 @@ func (t *TextReader) Seek(offset int64, whence int) (int64, error) {
 		return 0, errors.New("textreader: invalid whence")
 	}
 
-	if newR < 0 {
+	if target := int64(t.pos.Offset()-t.r) + newR; target < 0 {
 		return 0, errors.New("textreader: negative position")
+	} else if newR < 0 || newR > int64(len(t.buf)) {
+		return t.seekSource(target)
 	}
 
 	rel := newR - int64(t.r)
@@ -285,20 +580,18 @@ func (t *TextReader) Seek(offset int64, whence int) (int64, error) {
 		return int64(t.pos.Offset()), nil
 	}
 
-	// Check bounds before converting to int for buffer operations
-	if newR > int64(t.capacity) {
-		return 0, ErrSeekOutOfBuffer
-	}
 	newRInt := int(newR)
 	relInt := int(rel)
 
 	if rel > 0 { // Seeking Forward
-		if relInt > t.capacity {
-			return 0, ErrSeekOutOfBuffer
-		}
-
 		bytesAvailable := t.w - t.r
 		if relInt > bytesAvailable {
+			// Look-behind and marks can grow the buffer past the maximum
+			// capacity, but reading ahead can't.
+			if relInt > t.maxCapacity {
+				return t.seekSource(int64(t.pos.Offset() + relInt))
+			}
+
 			if _, err := t.fillAtLeast(relInt); err != nil && !errors.Is(err, io.EOF) {
 				return 0, fmt.Errorf("fillAtLeast: %w", err)
 			}
@@ -308,7 +601,7 @@ func (t *TextReader) Seek(offset int64, whence int) (int64, error) {
 			return 0, ErrSeekOutOfBuffer
 		}
 
-		t.pos.Scan(t.buf[t.r : t.r+relInt])
+		t.scan(t.buf[t.r : t.r+relInt])
 		t.r += relInt
 
 	} else { // Seeking Backward
@@ -323,7 +616,7 @@ func (t *TextReader) Seek(offset int64, whence int) (int64, error) {
 		t.r += relInt
 	}
 
-	t.lastRuneSize = -1
+	t.history.clear()
 
 	return int64(t.pos.Offset()), nil
 }
@@ -337,3 +630,15 @@ func (t *TextReader) Pos() *position.Position {
 
 	return t.pos.Copy()
 }
+
+// BeginSpan returns the current position as the start of a span, to be passed
+// to EndSpan once the text of the span is read.
+func (t *TextReader) BeginSpan() *position.Position {
+	return t.Pos()
+}
+
+// EndSpan returns the span from start, as returned by BeginSpan, up to the
+// current position.
+func (t *TextReader) EndSpan(start *position.Position) position.Span {
+	return position.Span{Start: start, End: t.Pos()}
+}
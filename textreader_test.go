@@ -663,7 +663,7 @@ func TestTextReaderEdgeCases(t *testing.T) {
 		// Verify internal buffer was reset after direct read
 		assert.Equal(t, 0, tr.w)
 		assert.Equal(t, 0, tr.r)
-		assert.Equal(t, -1, tr.lastRuneSize)
+		assert.Equal(t, 0, tr.history.n)
 
 		n, err = tr.Read(buf[:5])
 		require.NoError(t, err)
@@ -836,6 +836,428 @@ func TestSeekBackMultibyteUTF8(t *testing.T) {
 	assert.Equal(t, 4, pos.Column(), "Column should be 4 runes")
 }
 
+func TestLineTrackingManyLines(t *testing.T) {
+	const lines = 5000
+
+	text := strings.Repeat("abc\ndefgh\n", lines)
+	tr := newReader(text, 16)
+
+	out, err := readAllRunes(tr)
+	require.NoError(t, err)
+	assert.Equal(t, text, out)
+
+	pos := tr.Pos()
+	assert.Equal(t, 2*lines+1, pos.Line())
+	assert.Equal(t, 0, pos.Column())
+	assert.Equal(t, len(text), pos.Offset())
+
+	// The last lines are still in the buffer, so we can go back to them.
+	_, err = tr.Seek(-3, io.SeekCurrent)
+	require.NoError(t, err)
+
+	pos = tr.Pos()
+	assert.Equal(t, 2*lines, pos.Line())
+	assert.Equal(t, 3, pos.Column())
+	assert.Equal(t, len(text)-3, pos.Offset())
+}
+
+func TestUnreadRuneDepth(t *testing.T) {
+	t.Run("several runes in a row", func(t *testing.T) {
+		tr := New(strings.NewReader("ab\n界🦄c"), WithUnreadDepth(4))
+
+		out, err := readAllRunes(tr)
+		require.NoError(t, err)
+		assert.Equal(t, "ab\n界🦄c", out)
+
+		offsets := []int{10, 6, 3, 2}
+		for _, offset := range offsets {
+			require.NoError(t, tr.UnreadRune())
+			assert.Equal(t, offset, tr.Pos().Offset())
+		}
+
+		// Only four levels were requested.
+		assert.ErrorIs(t, tr.UnreadRune(), bufio.ErrInvalidUnreadRune)
+
+		pos := tr.Pos()
+		assert.Equal(t, 1, pos.Line())
+		assert.Equal(t, 2, pos.Column())
+
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, '\n', r)
+
+		// The history starts over from the rune we just read.
+		require.NoError(t, tr.UnreadRune())
+		assert.Equal(t, 2, tr.Pos().Offset())
+		assert.ErrorIs(t, tr.UnreadRune(), bufio.ErrInvalidUnreadRune)
+	})
+
+	t.Run("read and seek clear the history", func(t *testing.T) {
+		tr := New(strings.NewReader("abcdef"), WithUnreadDepth(8))
+
+		for i := 0; i < 3; i++ {
+			_, _, err := tr.ReadRune()
+			require.NoError(t, err)
+		}
+
+		_, err := tr.Read(make([]byte, 1))
+		require.NoError(t, err)
+		assert.ErrorIs(t, tr.UnreadRune(), bufio.ErrInvalidUnreadRune)
+
+		_, _, err = tr.ReadRune()
+		require.NoError(t, err)
+
+		_, err = tr.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		require.NoError(t, tr.UnreadRune())
+
+		_, err = tr.Seek(-1, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.ErrorIs(t, tr.UnreadRune(), bufio.ErrInvalidUnreadRune)
+	})
+
+	t.Run("compaction drops unreachable runes", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader("abcdefghij"), 6, WithUnreadDepth(10))
+
+		for i := 0; i < 5; i++ {
+			_, _, err := tr.ReadRune()
+			require.NoError(t, err)
+		}
+
+		// Reading the next rune shifts the buffer, only the runes that are still
+		// buffered can be unread.
+		_, _, err := tr.ReadRune()
+		require.NoError(t, err)
+
+		unread := 0
+		for tr.UnreadRune() == nil {
+			unread++
+		}
+
+		assert.Equal(t, 6-unread, tr.Pos().Offset())
+		assert.Equal(t, 0, tr.r)
+		assert.Less(t, unread, 6)
+	})
+}
+
+func TestVisualColumn(t *testing.T) {
+	tr := New(
+		strings.NewReader("if x {\n\treturn\t1\n}"),
+		WithUnreadDepth(2),
+		WithPositionOptions(position.WithTabWidth(4)),
+	)
+
+	_, err := io.ReadFull(tr, make([]byte, 15))
+	require.NoError(t, err)
+
+	pos := tr.Pos()
+	assert.Equal(t, 2, pos.Line())
+	assert.Equal(t, 8, pos.Column())
+	assert.Equal(t, 12, pos.VisualColumn())
+
+	_, err = tr.Seek(-2, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, 9, tr.Pos().VisualColumn())
+
+	r, _, err := tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, 'n', r)
+
+	r, _, err = tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, '\t', r)
+	assert.Equal(t, 12, tr.Pos().VisualColumn())
+
+	require.NoError(t, tr.UnreadRune())
+	require.NoError(t, tr.UnreadRune())
+	assert.Equal(t, 9, tr.Pos().VisualColumn())
+}
+
+func TestColumnModes(t *testing.T) {
+	text := "👩‍👩‍👧 日本 é\n"
+
+	tr := New(strings.NewReader(text), WithPositionOptions(position.WithColumnMode(position.Cells)))
+
+	out, err := readAllRunes(tr)
+	require.NoError(t, err)
+	assert.Equal(t, text, out)
+
+	_, err = tr.Seek(-1, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, 9, tr.Pos().Column())
+
+	_, err = tr.Seek(-3, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, 7, tr.Pos().Column())
+
+	tr = New(strings.NewReader(text), WithPositionOptions(position.WithColumnMode(position.Graphemes)))
+
+	_, err = io.ReadFull(tr, make([]byte, len(text)-1))
+	require.NoError(t, err)
+	assert.Equal(t, 6, tr.Pos().Column())
+
+	_, err = tr.Seek(int64(len("👩‍👩")), io.SeekStart)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tr.Pos().Column())
+
+	r, _, err := tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, '\u200d', r)
+	assert.Equal(t, 1, tr.Pos().Column())
+}
+
+func TestLineEndings(t *testing.T) {
+	text := "one\r\ntwo\r\nthree"
+
+	tr := New(strings.NewReader(text), WithPositionOptions(position.WithLineEndings(position.CRLF)))
+
+	out, err := readAllRunes(tr)
+	require.NoError(t, err)
+	assert.Equal(t, text, out)
+	assert.Equal(t, 3, tr.Pos().Line())
+	assert.Equal(t, 5, tr.Pos().Column())
+
+	// Land between the "\r" and the "\n".
+	_, err = tr.Seek(9, io.SeekStart)
+	require.NoError(t, err)
+	assert.Equal(t, 2, tr.Pos().Line())
+	assert.Equal(t, 4, tr.Pos().Column())
+
+	r, _, err := tr.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, '\n', r)
+	assert.Equal(t, 3, tr.Pos().Line())
+	assert.Equal(t, 0, tr.Pos().Column())
+
+	tr = New(strings.NewReader("a\rb\r\nc"), WithPositionOptions(position.WithLineEndings(position.Unicode)))
+
+	_, err = io.ReadFull(tr, make([]byte, 6))
+	require.NoError(t, err)
+	assert.Equal(t, 3, tr.Pos().Line())
+
+	_, err = tr.Seek(-3, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, 2, tr.Pos().Line())
+	assert.Equal(t, 1, tr.Pos().Column())
+
+	_, err = tr.Seek(1, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, 3, tr.Pos().Line())
+	assert.Equal(t, 0, tr.Pos().Column())
+
+	text, column, err := tr.CurrentLine()
+	require.NoError(t, err)
+	assert.Equal(t, "", text)
+	assert.Equal(t, 0, column)
+}
+
+func TestLineEndings_Compact(t *testing.T) {
+	opts := WithPositionOptions(position.WithLineEndings(position.Unicode))
+
+	// Compaction falls in between the "\r" and the "\n" of every break.
+	tr := NewWithCapacity(strings.NewReader("abc\r\nde\r\nfg"), 4, opts)
+	_, err := readAllRunes(tr)
+	require.NoError(t, err)
+	assert.Equal(t, 3, tr.Pos().Line())
+	assert.Equal(t, 2, tr.Pos().Column())
+
+	text := strings.Repeat("line\r\n", 1000)
+	tr = NewWithCapacity(struct{ io.Reader }{strings.NewReader(text)}, 16, opts)
+	_, err = io.Copy(io.Discard, tr)
+	require.NoError(t, err)
+	assert.Equal(t, 1001, tr.Pos().Line())
+
+	// Seeking a seekable source scans from a checkpoint, and compacts too.
+	tr = NewWithCapacity(strings.NewReader(text), 16, opts, WithRandomAccess())
+	_, err = tr.Seek(int64(len(text)-6), io.SeekStart)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, tr.Pos().Line())
+	assert.Equal(t, 0, tr.Pos().Column())
+}
+
+func TestLineEndings_CompactPendingCR(t *testing.T) {
+	opts := WithPositionOptions(position.WithLineEndings(position.CRLF))
+
+	// Compaction falls right after the "\r" of the first break.
+	tr := NewWithCapacity(strings.NewReader("x\t\r\n🦄\r\n"), 4, opts)
+	for i := 0; i < 4; i++ {
+		_, _, err := tr.ReadRune()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, tr.Pos().Line())
+
+	require.NoError(t, tr.UnreadRune())
+	assert.Equal(t, 1, tr.Pos().Line())
+	assert.Equal(t, 9, tr.Pos().VisualColumn())
+}
+
+func TestPeek(t *testing.T) {
+	t.Run("does not advance", func(t *testing.T) {
+		tr := newReader("hello\nworld", 8)
+
+		b, err := tr.Peek(3)
+		require.NoError(t, err)
+		assert.Equal(t, "hel", string(b))
+		assert.Equal(t, 0, tr.Pos().Offset())
+
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 'h', r)
+
+		b, err = tr.Peek(7)
+		require.NoError(t, err)
+		assert.Equal(t, "ello\nwo", string(b))
+
+		pos := tr.Pos()
+		assert.Equal(t, 1, pos.Line())
+		assert.Equal(t, 1, pos.Column())
+		assert.Equal(t, 1, pos.Offset())
+	})
+
+	t.Run("short read at EOF", func(t *testing.T) {
+		tr := newReader("abc", 8)
+
+		b, err := tr.Peek(5)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Equal(t, "abc", string(b))
+
+		b, err = tr.Peek(0)
+		require.NoError(t, err)
+		assert.Empty(t, b)
+	})
+
+	t.Run("larger than capacity", func(t *testing.T) {
+		tr := newReader("0123456789", 5)
+
+		_, err := tr.Peek(6)
+		assert.ErrorIs(t, err, ErrBufferTooSmall)
+
+		b, err := tr.Peek(5)
+		require.NoError(t, err)
+		assert.Equal(t, "01234", string(b))
+
+		_, err = tr.Peek(-1)
+		assert.Error(t, err)
+	})
+}
+
+func TestPeekRune(t *testing.T) {
+	t.Run("look ahead", func(t *testing.T) {
+		tr := newReader("a界🦄b", 16)
+
+		expected := []struct {
+			r    rune
+			size int
+		}{
+			{'a', 1},
+			{'界', 3},
+			{'🦄', 4},
+			{'b', 1},
+		}
+
+		for k, exp := range expected {
+			r, size, err := tr.PeekRune(k)
+			require.NoError(t, err)
+			assert.Equal(t, exp.r, r)
+			assert.Equal(t, exp.size, size)
+		}
+
+		_, _, err := tr.PeekRune(len(expected))
+		assert.ErrorIs(t, err, io.EOF)
+
+		_, _, err = tr.PeekRune(-1)
+		assert.Error(t, err)
+
+		assert.Equal(t, 0, tr.Pos().Offset())
+
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 'a', r)
+
+		r, _, err = tr.PeekRune(1)
+		require.NoError(t, err)
+		assert.Equal(t, '🦄', r)
+
+		// Peeking does not interfere with UnreadRune.
+		require.NoError(t, tr.UnreadRune())
+		assert.Equal(t, 0, tr.Pos().Offset())
+	})
+
+	t.Run("across buffer refills", func(t *testing.T) {
+		tr := newReader("abcdef界ghij", 10)
+
+		for i := 0; i < 5; i++ {
+			_, _, err := tr.ReadRune()
+			require.NoError(t, err)
+		}
+
+		r, size, err := tr.PeekRune(1)
+		require.NoError(t, err)
+		assert.Equal(t, '界', r)
+		assert.Equal(t, 3, size)
+
+		r, _, err = tr.PeekRune(5)
+		require.NoError(t, err)
+		assert.Equal(t, 'j', r)
+
+		pos := tr.Pos()
+		assert.Equal(t, 5, pos.Offset())
+		assert.Equal(t, 5, pos.Column())
+
+		r, _, err = tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 'f', r)
+	})
+
+	t.Run("beyond capacity", func(t *testing.T) {
+		tr := newReader(strings.Repeat("x", 20), 6)
+
+		_, _, err := tr.PeekRune(5)
+		require.NoError(t, err)
+
+		_, _, err = tr.PeekRune(6)
+		assert.ErrorIs(t, err, ErrBufferTooSmall)
+	})
+
+	t.Run("end of input", func(t *testing.T) {
+		tr := newReader("ab", 4)
+
+		r, _, err := tr.PeekRune(1)
+		require.NoError(t, err)
+		assert.Equal(t, 'b', r)
+
+		_, _, err = tr.PeekRune(2)
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("rune just fits", func(t *testing.T) {
+		tr := newReader("abé and more", 4)
+
+		r, size, err := tr.PeekRune(2)
+		require.NoError(t, err)
+		assert.Equal(t, 'é', r)
+		assert.Equal(t, 2, size)
+
+		// Only the first byte of the euro sign fits.
+		tr = newReader("abc€ and more", 4)
+		_, _, err = tr.PeekRune(3)
+		assert.ErrorIs(t, err, ErrBufferTooSmall)
+	})
+
+	t.Run("invalid UTF-8", func(t *testing.T) {
+		tr := newReader("a\xffb", 8)
+
+		r, size, err := tr.PeekRune(1)
+		require.NoError(t, err)
+		assert.Equal(t, utf8.RuneError, r)
+		assert.Equal(t, 1, size)
+
+		r, _, err = tr.PeekRune(2)
+		require.NoError(t, err)
+		assert.Equal(t, 'b', r)
+	})
+}
+
 func TestPositionScanRewind(t *testing.T) {
 	pos := position.New()
 
@@ -860,3 +1282,189 @@ func TestPositionScanRewind(t *testing.T) {
 	err = pos.Rewind(10, 10)
 	assert.Error(t, err, "Rewinding past beginning should return error")
 }
+
+func TestSpan(t *testing.T) {
+	tr := NewNamed("main.go", strings.NewReader("let x = 42\nlet y = x"))
+
+	spanOf := func(word string) position.Span {
+		for {
+			b, err := tr.Peek(len(word))
+			require.NoError(t, err)
+			if string(b) == word {
+				break
+			}
+
+			_, _, err = tr.ReadRune()
+			require.NoError(t, err)
+		}
+
+		start := tr.BeginSpan()
+		_, err := tr.Seek(int64(len(word)), io.SeekCurrent)
+		require.NoError(t, err)
+		return tr.EndSpan(start)
+	}
+
+	num := spanOf("42")
+	assert.Equal(t, "main.go:1:8-1:10", num.String())
+
+	y := spanOf("y = x")
+	assert.Equal(t, "main.go:2:4-2:9", y.String())
+	assert.Equal(t, "main.go:1:8-2:9", num.Union(y).String())
+}
+
+func TestMaxCapacity(t *testing.T) {
+	text := strings.Repeat("abcdefgh", 8) + "\n" + strings.Repeat("xy", 40)
+
+	t.Run("peek", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader(text), 16, WithMaxCapacity(64))
+
+		b, err := tr.Peek(50)
+		require.NoError(t, err)
+		assert.Equal(t, text[:50], string(b))
+		assert.Equal(t, 64, len(tr.buf))
+
+		_, err = tr.Peek(65)
+		assert.ErrorIs(t, err, ErrBufferTooSmall)
+
+		// Once the data fits again the buffer goes back to its capacity.
+		_, err = tr.Seek(60, io.SeekCurrent)
+		require.NoError(t, err)
+		for i := 0; i < 20; i++ {
+			_, _, err := tr.ReadRune()
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 16, len(tr.buf))
+	})
+
+	t.Run("long lines", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader(text), 16, WithMaxCapacity(128))
+
+		line, err := tr.ReadSlice('\n')
+		require.NoError(t, err)
+		assert.Equal(t, text[:65], string(line))
+
+		s := NewScanner(tr)
+		require.True(t, s.Scan())
+		assert.Equal(t, strings.Repeat("xy", 40), s.Text())
+		assert.Equal(t, "2:80", s.End().String())
+	})
+
+	t.Run("ceiling", func(t *testing.T) {
+		tr := NewWithCapacity(strings.NewReader(text), 16, WithMaxCapacity(32))
+
+		line, err := tr.ReadSlice('\n')
+		assert.ErrorIs(t, err, bufio.ErrBufferFull)
+		assert.Equal(t, text[:32], string(line))
+	})
+}
+
+func TestLookBehind(t *testing.T) {
+	text := "first line\nsecond line\nthird line\nfourth line\n"
+
+	// A plain stream, so that seeking can't fall back on the source.
+	stream := func(s string) io.Reader {
+		return struct{ io.Reader }{strings.NewReader(s)}
+	}
+
+	readN := func(t *testing.T, tr *TextReader, n int) {
+		for i := 0; i < n; i++ {
+			_, _, err := tr.ReadRune()
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("seek back after refill", func(t *testing.T) {
+		tr := NewWithCapacity(stream(text), 8, WithLookBehind(6))
+
+		readN(t, tr, 30)
+		_, err := tr.Seek(-6, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, "3:1", tr.Pos().String())
+
+		r, _, err := tr.ReadRune()
+		require.NoError(t, err)
+		assert.Equal(t, 'h', r)
+
+		// Without a look-behind window the same seek fails.
+		tr = NewWithCapacity(stream(text), 8)
+		readN(t, tr, 30)
+		_, err = tr.Seek(-6, io.SeekCurrent)
+		assert.ErrorIs(t, err, ErrSeekOutOfBuffer)
+	})
+
+	t.Run("seek forward within the buffer", func(t *testing.T) {
+		tr := NewWithCapacity(stream(text), 8, WithLookBehind(16))
+
+		readN(t, tr, 30)
+		_, err := tr.Seek(-12, io.SeekCurrent)
+		require.NoError(t, err)
+
+		// Going back to where we were doesn't need more than the buffer holds,
+		// even if it's more than the maximum capacity.
+		offset, err := tr.Seek(12, io.SeekCurrent)
+		require.NoError(t, err)
+		assert.Equal(t, int64(30), offset)
+		assert.Equal(t, "3:7", tr.Pos().String())
+	})
+
+	t.Run("previous line", func(t *testing.T) {
+		tr := NewWithCapacity(stream(text), 8, WithLookBehind(12))
+
+		// Read up to "third line" and make the reader refill.
+		readN(t, tr, 23)
+		_, err := tr.Peek(8)
+		require.NoError(t, err)
+
+		snip, err := tr.Context(12, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "second line\n", snip.Text)
+		assert.Equal(t, "2:0", snip.Start.String())
+	})
+
+	t.Run("previous line with other line endings", func(t *testing.T) {
+		text := strings.ReplaceAll(text, "\n", "\r")
+		opts := WithPositionOptions(position.WithLineEndings(position.CR))
+		tr := NewWithCapacity(stream(text), 8, WithLookBehind(8), opts)
+
+		// Read up to "third line" and make the reader refill. The window
+		// reaches back into "second line", and then to its start.
+		readN(t, tr, 23)
+		_, err := tr.Peek(8)
+		require.NoError(t, err)
+
+		snip, err := tr.Context(12, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "second line\r", snip.Text)
+		assert.Equal(t, "2:0", snip.Start.String())
+	})
+
+	t.Run("on top of the maximum capacity", func(t *testing.T) {
+		text := strings.Repeat("x", 200)
+		tr := NewWithCapacity(stream(text), 16, WithMaxCapacity(64), WithLookBehind(32))
+
+		readN(t, tr, 100)
+		b, err := tr.Peek(64)
+		require.NoError(t, err)
+		assert.Len(t, b, 64)
+		assert.LessOrEqual(t, len(tr.buf), 64+2*32)
+
+		_, err = tr.Seek(-32, io.SeekCurrent)
+		require.NoError(t, err)
+
+		_, err = tr.Peek(65)
+		assert.ErrorIs(t, err, ErrBufferTooSmall)
+	})
+
+	t.Run("runes", func(t *testing.T) {
+		tr := NewWithCapacity(stream("añbñcñdñeñfñgñhñ"), 4, WithLookBehind(2))
+
+		readN(t, tr, 13)
+		_, err := tr.Peek(4)
+		require.NoError(t, err)
+
+		snip, err := tr.Context(3, 0)
+		require.NoError(t, err)
+		assert.True(t, utf8.ValidString(snip.Text))
+		assert.GreaterOrEqual(t, len(snip.Text), 2)
+	})
+}